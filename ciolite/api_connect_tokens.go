@@ -3,9 +3,9 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/connect_tokens
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/contextio/contextio-go/cioutil"
 	"github.com/pkg/errors"
@@ -56,24 +56,24 @@ type GetConnectTokenUserResponse struct {
 // 	https://context.io/docs/lite/users/connect_tokens#post
 type CreateConnectTokenParams struct {
 	// Required:
-	CallbackURL string `json:"callback_url"`
+	CallbackURL string `json:"callback_url" valid:"required,url"`
 
 	// Optional:
 	Email             string `json:"email,omitempty"`
 	FirstName         string `json:"first_name,omitempty"`
 	LastName          string `json:"last_name,omitempty"`
-	StatusCallbackURL string `json:"status_callback_url,omitempty"`
+	StatusCallbackURL string `json:"status_callback_url,omitempty" valid:"url"`
 }
 
 // CreateConnectTokenResponse data struct
 // 	https://context.io/docs/lite/connect_tokens#post
 type CreateConnectTokenResponse struct {
-	Success            bool   `json:"success,omitempty"`
-	Token              string `json:"token,omitempty"`
-	ResourceURL        string `json:"resource_url,omitempty"`
-	BrowserRedirectURL string `json:"browser_redirect_url,omitempty"`
-	AccessToken        string `json:"access_token,omitempty"`
-	AccessTokenSecret  string `json:"access_token_secret,omitempty"`
+	Success            bool           `json:"success,omitempty"`
+	Token              string         `json:"token,omitempty"`
+	ResourceURL        string         `json:"resource_url,omitempty"`
+	BrowserRedirectURL string         `json:"browser_redirect_url,omitempty"`
+	AccessToken        string         `json:"access_token,omitempty"`
+	AccessTokenSecret  cioutil.Secret `json:"access_token_secret,omitempty"`
 }
 
 // DeleteConnectTokenResponse data struct
@@ -107,8 +107,9 @@ func (cioLite CioLite) GetConnectToken(token string) (GetConnectTokenResponse, e
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/connect_tokens/%s", token),
+		Method:     "GET",
+		Path:       "/connect_tokens/{token}",
+		PathValues: cioutil.PathValues{"token": token},
 	}
 
 	// Make response
@@ -148,8 +149,9 @@ func (cioLite CioLite) DeleteConnectToken(token string) (DeleteConnectTokenRespo
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "DELETE",
-		Path:   fmt.Sprintf("/connect_tokens/%s", token),
+		Method:     "DELETE",
+		Path:       "/connect_tokens/{token}",
+		PathValues: cioutil.PathValues{"token": token},
 	}
 
 	// Make response
@@ -167,34 +169,130 @@ func (user GetConnectTokenUserResponse) EmailAccountMatching(email string) (GetU
 	return FindEmailAccountMatching(user.EmailAccounts, email)
 }
 
+// CreatedTime returns Created as a time.Time, or the zero time.Time if not set.
+func (connectToken GetConnectTokenResponse) CreatedTime() time.Time {
+	return cioutil.UnixTime(connectToken.Created)
+}
+
+// UsedTime returns Used as a time.Time, or the zero time.Time if the token has not been used.
+func (connectToken GetConnectTokenResponse) UsedTime() time.Time {
+	return cioutil.UnixTime(connectToken.Used)
+}
+
+// CreatedTime returns Created as a time.Time, or the zero time.Time if not set.
+func (user GetConnectTokenUserResponse) CreatedTime() time.Time {
+	return cioutil.UnixTime(user.Created)
+}
+
+// ConnectTokenOutcome is the outcome of checking a connect token
+type ConnectTokenOutcome int
+
+const (
+	// ConnectTokenOK means the token was used, the email matches, and CIO has access to the account
+	ConnectTokenOK ConnectTokenOutcome = iota
+
+	// ConnectTokenNotUsed means the token has not been used (accepted/authorized) yet
+	ConnectTokenNotUsed
+
+	// ConnectTokenExpired means the token expired before being used
+	ConnectTokenExpired
+
+	// ConnectTokenEmailMismatch means the email authorized does not match the expected email
+	ConnectTokenEmailMismatch
+
+	// ConnectTokenNoUser means the token was used, but the CIO user has not been created yet
+	ConnectTokenNoUser
+
+	// ConnectTokenNoAccess means the token was used, but CIO is unable to access the email account
+	ConnectTokenNoAccess
+)
+
+// String returns the description of the outcome
+func (outcome ConnectTokenOutcome) String() string {
+	switch outcome {
+	case ConnectTokenOK:
+		return "Context.io token OK"
+	case ConnectTokenNotUsed:
+		return "Context.io token not used yet"
+	case ConnectTokenExpired:
+		return "Context.io token expired"
+	case ConnectTokenEmailMismatch:
+		return "Email does not match Context.io token"
+	case ConnectTokenNoUser:
+		return "Context.io user not created yet"
+	case ConnectTokenNoAccess:
+		return "Unable to access account using Context.io"
+	}
+	return "Unknown Context.io token outcome"
+}
+
+// ConnectTokenError is the error returned by CheckConnectToken and WaitForConnectToken
+// when a connect token is not (or not yet) usable, with the Outcome describing why.
+type ConnectTokenError struct {
+	Outcome ConnectTokenOutcome
+	Token   string
+}
+
+// Error returns the description of the Outcome
+func (e ConnectTokenError) Error() string {
+	return e.Outcome.String()
+}
+
+// AsConnectTokenError returns the ConnectTokenError if err is (or was caused by) one
+func AsConnectTokenError(err error) (ConnectTokenError, bool) {
+	connectTokenErr, ok := errors.Cause(err).(ConnectTokenError)
+	return connectTokenErr, ok
+}
+
+// ConnectTokenOutcomeOf returns the outcome of the connect token error,
+// ConnectTokenOK if err is nil, or -1 if err is some other error.
+func ConnectTokenOutcomeOf(err error) ConnectTokenOutcome {
+	if err == nil {
+		return ConnectTokenOK
+	}
+	if connectTokenErr, ok := AsConnectTokenError(err); ok {
+		return connectTokenErr.Outcome
+	}
+	return -1
+}
+
 // CheckConnectToken checks and returns nil if the connect token was used, the email
 // authorized matches the expected email, and that CIO has access to the account.
+// Otherwise returns a ConnectTokenError with the Outcome.
 func (cioLite CioLite) CheckConnectToken(connectToken GetConnectTokenResponse, email string) error {
 
 	// Confirm email matches
 	if strings.ToLower(connectToken.Email) != strings.ToLower(email) {
-		return errors.New("Email does not match Context.io token")
+		return ConnectTokenError{Outcome: ConnectTokenEmailMismatch, Token: connectToken.Token}
 	}
 
-	// Confirm token was used (accepted/authorized)
-	if connectToken.Used == 0 || connectToken.Expires.Unused() {
-		return errors.New("Context.io token not used yet")
+	// Confirm token was used (accepted/authorized), even if CIO has not yet set expires to false
+	if connectToken.Used == 0 {
+		if connectToken.Expired() {
+			return ConnectTokenError{Outcome: ConnectTokenExpired, Token: connectToken.Token}
+		}
+		return ConnectTokenError{Outcome: ConnectTokenNotUsed, Token: connectToken.Token}
 	}
 
 	// Confirm user exists
 	if len(connectToken.User.ID) == 0 {
-		return errors.New("Context.io user not created yet")
+		return ConnectTokenError{Outcome: ConnectTokenNoUser, Token: connectToken.Token}
 	}
 
 	// Confirm we have access
 	account, err := connectToken.User.EmailAccountMatching(email)
 	if err != nil || account.Status != "OK" {
-		return errors.New("Unable to access account using Context.io")
+		return ConnectTokenError{Outcome: ConnectTokenNoAccess, Token: connectToken.Token}
 	}
 
 	return nil
 }
 
+// Expired returns true if the connect token is unused and its expires time has passed
+func (connectToken GetConnectTokenResponse) Expired() bool {
+	return connectToken.Used == 0 && connectToken.Expires.Unused() && !connectToken.Expires.Time().After(time.Now())
+}
+
 // ExpiresMixed is a special type to handle the fact that 'expires' can be an int or false.
 // 	Unix timestamp of when this token will expire and be purged.
 // 	Once the token is used, this property will be set to false
@@ -218,6 +316,15 @@ func (expires *ExpiresMixed) Timestamp() int {
 	return *expires.Expires
 }
 
+// Time returns the expires timestamp as a time.Time if the token is unused,
+// and returns the zero time.Time if the token has been used.
+func (expires *ExpiresMixed) Time() time.Time {
+	if expires.Expires == nil {
+		return time.Time{}
+	}
+	return cioutil.UnixTime(*expires.Expires)
+}
+
 // MarshalJSON allows ExpiresMixed to implement json.Marshaler
 func (expires ExpiresMixed) MarshalJSON() ([]byte, error) {
 	if expires.Expires == nil {
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestActualConnectTokenRequestToCioForGoogle tests actual CreateConnectToken,
@@ -182,6 +183,14 @@ func TestSimulatedGetConnectToken(t *testing.T) {
 		t.Error("Expected: ", expected, "; Got: ", getConnectToken, "; With Error: ", err, "; With Log: ", logger.String())
 	}
 
+	// Check time accessors
+	if !getConnectToken.CreatedTime().Equal(time.Unix(1462217246, 0)) ||
+		!getConnectToken.UsedTime().Equal(time.Unix(1462217259, 0)) ||
+		!getConnectToken.User.CreatedTime().Equal(time.Unix(1462217251, 0)) ||
+		!getConnectToken.Expires.Time().IsZero() {
+		t.Error("Expected time accessors to match timestamps; Got: ", getConnectToken)
+	}
+
 	// Check Connect Token
 	err = cioLite.CheckConnectToken(getConnectToken, "test@gmail.com")
 	if err != nil {
@@ -220,7 +229,8 @@ func TestExpiresMixed(t *testing.T) {
 
 	if !reflect.DeepEqual(falseExpires, falseExpectedExpires) ||
 		falseExpires.MyField.Unused() ||
-		falseExpires.MyField.Timestamp() != -1 {
+		falseExpires.MyField.Timestamp() != -1 ||
+		!falseExpires.MyField.Time().IsZero() {
 		t.Error(falseExpires)
 	}
 
@@ -237,7 +247,8 @@ func TestExpiresMixed(t *testing.T) {
 
 	if !reflect.DeepEqual(timestampExpires, timestampExpectedExpires) ||
 		!timestampExpires.MyField.Unused() ||
-		timestampExpires.MyField.Timestamp() != timestamp {
+		timestampExpires.MyField.Timestamp() != timestamp ||
+		!timestampExpires.MyField.Time().Equal(time.Unix(int64(timestamp), 0)) {
 		t.Error(timestampExpires)
 	}
 
@@ -9,8 +9,8 @@ import "github.com/contextio/contextio-go/cioutil"
 // 	https://context.io/docs/lite/discovery#get
 type GetDiscoveryParams struct {
 	// Required:
-	SourceType string `json:"source_type"`
-	Email      string `json:"email"`
+	SourceType string `json:"source_type" valid:"required,in(IMAP)"`
+	Email      string `json:"email" valid:"required"`
 }
 
 // GetDiscoveryResponse data struct
@@ -1,8 +1,11 @@
 package ciolite
 
 import (
+	"net/http"
 	"reflect"
 	"testing"
+
+	"github.com/contextio/contextio-go/cioutil"
 )
 
 // TestActualDiscoveryRequestToCioForGoogle tests sending an actual
@@ -193,3 +196,23 @@ func TestActualDiscoveryRequestToCioForNonExistent(t *testing.T) {
 		t.Error("Expected GetDiscovery Response: ", expected, "; Got: ", response, "; With Error: ", err)
 	}
 }
+
+// TestSimulatedDiscoveryValidation tests that invalid GetDiscovery params
+// are rejected before any request is made to the simulated server
+func TestSimulatedDiscoveryValidation(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/discovery", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to be made; Got: ", r.URL)
+	})
+
+	_, err := cioLite.GetDiscovery(GetDiscoveryParams{SourceType: "POP3"})
+
+	validationErr, ok := cioutil.AsValidationError(err)
+	if !ok || len(validationErr.Fields) != 2 {
+		t.Error("Expected validation error for source_type and email; Got: ", err, "; With Log: ", logger.String())
+	}
+}
@@ -3,8 +3,6 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/connect_tokens
 
 import (
-	"fmt"
-
 	"github.com/contextio/contextio-go/cioutil"
 )
 
@@ -12,10 +10,10 @@ import (
 // 	https://context.io/docs/lite/oauth_providers#get
 // 	https://context.io/docs/lite/oauth_providers#id-get
 type GetOAuthProvidersResponse struct {
-	Type                   string `json:"type,omitempty"`
-	ProviderConsumerKey    string `json:"provider_consumer_key,omitempty"`
-	ProviderConsumerSecret string `json:"provider_consumer_secret,omitempty"`
-	ResourceURL            string `json:"resource_url,omitempty"`
+	Type                   string         `json:"type,omitempty"`
+	ProviderConsumerKey    string         `json:"provider_consumer_key,omitempty"`
+	ProviderConsumerSecret cioutil.Secret `json:"provider_consumer_secret,omitempty"`
+	ResourceURL            string         `json:"resource_url,omitempty"`
 }
 
 // CreateOAuthProviderParams form values data struct.
@@ -23,9 +21,9 @@ type GetOAuthProvidersResponse struct {
 // 	https://context.io/docs/lite/oauth_providers#post
 type CreateOAuthProviderParams struct {
 	// Requires:
-	Type                   string `json:"type"`
-	ProviderConsumerKey    string `json:"provider_consumer_key"`
-	ProviderConsumerSecret string `json:"provider_consumer_secret"`
+	Type                   string         `json:"type" valid:"required"`
+	ProviderConsumerKey    string         `json:"provider_consumer_key" valid:"required"`
+	ProviderConsumerSecret cioutil.Secret `json:"provider_consumer_secret" valid:"required"`
 }
 
 // CreateOAuthProviderResponse data struct
@@ -67,8 +65,9 @@ func (cioLite CioLite) GetOAuthProvider(key string) (GetOAuthProvidersResponse,
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/oauth_providers/%s", key),
+		Method:     "GET",
+		Path:       "/oauth_providers/{key}",
+		PathValues: cioutil.PathValues{"key": key},
 	}
 
 	// Make response
@@ -107,8 +106,9 @@ func (cioLite CioLite) DeleteOAuthProvider(key string) (DeleteOAuthProviderRespo
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "DELETE",
-		Path:   fmt.Sprintf("/oauth_providers/%s", key),
+		Method:     "DELETE",
+		Path:       "/oauth_providers/{key}",
+		PathValues: cioutil.PathValues{"key": key},
 	}
 
 	// Make response
@@ -1,9 +1,11 @@
 package ciolite
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -68,6 +70,13 @@ func TestSimulatedGetOauthProvider(t *testing.T) {
 		t.Error("Expected: ", expected, "; Got: ", oauthProvider, "; With Error: ", err, "; With Log: ", logger.String())
 	}
 
+	if printed := fmt.Sprintf("%+v", oauthProvider); strings.Contains(printed, "M9k") {
+		t.Error("Expected redacted ProviderConsumerSecret; Got: ", printed)
+	}
+	if oauthProvider.ProviderConsumerSecret.Reveal() != "A2i[...]M9k" {
+		t.Error("Expected: A2i[...]M9k; Got: ", oauthProvider.ProviderConsumerSecret.Reveal())
+	}
+
 	if len(logger.String()) < 20 {
 		t.Error("Expected some output from logger; Got: ", logger.String())
 	}
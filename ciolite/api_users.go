@@ -3,7 +3,7 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/contextio/contextio-go/cioutil"
 )
@@ -14,8 +14,8 @@ import (
 type GetUsersParams struct {
 	// Optional:
 	Email    string `json:"email,omitempty"`
-	Status   string `json:"status,omitempty"`
-	StatusOK string `json:"status_ok,omitempty"`
+	Status   string `json:"status,omitempty" valid:"in(OK|CONNECTION_IMPOSSIBLE|INVALID_CREDENTIALS|TEMP_DISABLED|DISABLED)"`
+	StatusOK string `json:"status_ok,omitempty" valid:"in(0|1)"`
 	Limit    int    `json:"limit,omitempty"`
 	Offset   int    `json:"offset,omitempty"`
 }
@@ -44,10 +44,9 @@ type GetUsersResponse struct {
 // Email, Server, Username, UseSSL, Port, Type,
 // and (if OAUTH) ProviderRefreshToken and ProviderConsumerKey,
 // and (if not OAUTH) Password,
-// and may optionally contain StatusCallbackURL,
-// and (only for CreateUser) MigrateAccountID, FirstName, LastName.
+// and may optionally contain StatusCallbackURL, MigrateAccountID, FirstName, LastName.
+// To add an email account to an existing user, use CreateEmailAccountParams.
 // 	https://context.io/docs/lite/users#post
-// 	https://context.io/docs/lite/users/email_accounts#post
 type CreateUserParams struct {
 	// Optional, but Required for creating an Email Account
 	Email    string `json:"email,omitempty"`
@@ -58,19 +57,17 @@ type CreateUserParams struct {
 	Port     int    `json:"port,omitempty"`
 
 	// Optional, but Required for OAUTH:
-	ProviderRefreshToken string `json:"provider_refresh_token,omitempty"`
-	ProviderConsumerKey  string `json:"provider_consumer_key,omitempty"`
+	ProviderRefreshToken cioutil.Secret `json:"provider_refresh_token,omitempty"`
+	ProviderConsumerKey  string         `json:"provider_consumer_key,omitempty"`
 
 	// Optional, but Required for non-OAUTH:
-	Password string `json:"password,omitempty"`
+	Password cioutil.Secret `json:"password,omitempty"`
 
 	// Optional:
-	StatusCallbackURL string `json:"status_callback_url,omitempty"`
-
-	// Optional for CreaseUser only (not used by CreateUserEmailAccount):
-	MigrateAccountID string `json:"migrate_account_id,omitempty"`
-	FirstName        string `json:"first_name,omitempty"`
-	LastName         string `json:"last_name,omitempty"`
+	StatusCallbackURL string `json:"status_callback_url,omitempty" valid:"url"`
+	MigrateAccountID  string `json:"migrate_account_id,omitempty"`
+	FirstName         string `json:"first_name,omitempty"`
+	LastName          string `json:"last_name,omitempty"`
 }
 
 // CreateUserResponse data struct
@@ -81,9 +78,9 @@ type CreateUserResponse struct {
 
 	EmailAccount CreateEmailAccountResponse `json:"email_account,omitempty"`
 
-	ResourceURL       string `json:"resource_url,omitempty"`
-	AccessToken       string `json:"access_token,omitempty"`
-	AccessTokenSecret string `json:"access_token_secret,omitempty"`
+	ResourceURL       string         `json:"resource_url,omitempty"`
+	AccessToken       string         `json:"access_token,omitempty"`
+	AccessTokenSecret cioutil.Secret `json:"access_token_secret,omitempty"`
 
 	ConnectionLog string `json:"connection_log,omitempty"`
 	FeedbackCode  string `json:"feedback_code,omitempty"`
@@ -94,8 +91,8 @@ type CreateUserResponse struct {
 // 	https://context.io/docs/lite/users#id-post
 type ModifyUserParams struct {
 	// Requires:
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
+	FirstName string `json:"first_name" valid:"required"`
+	LastName  string `json:"last_name" valid:"required"`
 }
 
 // ModifyUserResponse data struct
@@ -133,14 +130,35 @@ func (cioLite CioLite) GetUsers(queryValues GetUsersParams) ([]GetUsersResponse,
 	return response, err
 }
 
+// GetAllUsers pages through GetUsers (using Limit and Offset), returning all users.
+// queryValues may optionally contain Email, Status, StatusOK, and Limit (the page size, defaulting to 100)
+func (cioLite CioLite) GetAllUsers(queryValues GetUsersParams) ([]GetUsersResponse, error) {
+	if queryValues.Limit <= 0 {
+		queryValues.Limit = 100
+	}
+
+	var users []GetUsersResponse
+	for queryValues.Offset = 0; ; queryValues.Offset += queryValues.Limit {
+		page, err := cioLite.GetUsers(queryValues)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, page...)
+		if len(page) < queryValues.Limit {
+			return users, nil
+		}
+	}
+}
+
 // GetUser get details about a given user.
 // 	https://context.io/docs/lite/users#id-get
 func (cioLite CioLite) GetUser(userID string) (GetUsersResponse, error) {
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/users/%s", userID),
+		Method:     "GET",
+		Path:       "/users/{id}",
+		PathValues: cioutil.PathValues{"id": userID},
 	}
 
 	// Make response
@@ -186,7 +204,8 @@ func (cioLite CioLite) ModifyUser(userID string, formValues ModifyUserParams) (M
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s", userID),
+		Path:       "/users/{id}",
+		PathValues: cioutil.PathValues{"id": userID},
 		FormValues: formValues,
 	}
 
@@ -205,8 +224,9 @@ func (cioLite CioLite) DeleteUser(userID string) (DeleteUserResponse, error) {
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "DELETE",
-		Path:   fmt.Sprintf("/users/%s", userID),
+		Method:     "DELETE",
+		Path:       "/users/{id}",
+		PathValues: cioutil.PathValues{"id": userID},
 	}
 
 	// Make response
@@ -223,3 +243,18 @@ func (cioLite CioLite) DeleteUser(userID string) (DeleteUserResponse, error) {
 func (user GetUsersResponse) EmailAccountMatching(email string) (GetUsersEmailAccountsResponse, error) {
 	return FindEmailAccountMatching(user.EmailAccounts, email)
 }
+
+// CreatedTime returns Created as a time.Time, or the zero time.Time if not set.
+func (user GetUsersResponse) CreatedTime() time.Time {
+	return cioutil.UnixTime(user.Created)
+}
+
+// SuspendedTime returns Suspended as a time.Time, or the zero time.Time if the user is not suspended.
+func (user GetUsersResponse) SuspendedTime() time.Time {
+	return cioutil.UnixTime(user.Suspended)
+}
+
+// PasswordExpiredTime returns PasswordExpired as a time.Time, or the zero time.Time if the password has not expired.
+func (user GetUsersResponse) PasswordExpiredTime() time.Time {
+	return cioutil.UnixTime(user.PasswordExpired)
+}
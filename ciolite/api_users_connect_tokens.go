@@ -3,8 +3,6 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/connect_tokens
 
 import (
-	"fmt"
-
 	"github.com/contextio/contextio-go/cioutil"
 )
 
@@ -14,8 +12,9 @@ func (cioLite CioLite) GetUserConnectTokens(userID string) ([]GetConnectTokenRes
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/users/%s/connect_tokens", userID),
+		Method:     "GET",
+		Path:       "/users/{id}/connect_tokens",
+		PathValues: cioutil.PathValues{"id": userID},
 	}
 
 	// Make response
@@ -33,8 +32,9 @@ func (cioLite CioLite) GetUserConnectToken(userID string, token string) (GetConn
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/users/%s/connect_tokens/%s", userID, token),
+		Method:     "GET",
+		Path:       "/users/{id}/connect_tokens/{token}",
+		PathValues: cioutil.PathValues{"id": userID, "token": token},
 	}
 
 	// Make response
@@ -55,7 +55,8 @@ func (cioLite CioLite) CreateUserConnectToken(userID string, formValues CreateCo
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s/connect_tokens", userID),
+		Path:       "/users/{id}/connect_tokens",
+		PathValues: cioutil.PathValues{"id": userID},
 		FormValues: formValues,
 	}
 
@@ -74,8 +75,9 @@ func (cioLite CioLite) DeleteUserConnectToken(userID string, token string) (Dele
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "DELETE",
-		Path:   fmt.Sprintf("/users/%s/connect_tokens/%s", userID, token),
+		Method:     "DELETE",
+		Path:       "/users/{id}/connect_tokens/{token}",
+		PathValues: cioutil.PathValues{"id": userID, "token": token},
 	}
 
 	// Make response
@@ -3,8 +3,8 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/email_accounts
 
 import (
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/contextio/contextio-go/cioutil"
 	"github.com/pkg/errors"
@@ -15,8 +15,8 @@ import (
 // 	https://context.io/docs/lite/users#get
 type GetUserEmailAccountsParams struct {
 	// Optional:
-	Status   string `json:"status,omitempty"`
-	StatusOK string `json:"status_ok,omitempty"`
+	Status   string `json:"status,omitempty" valid:"in(OK|CONNECTION_IMPOSSIBLE|INVALID_CREDENTIALS|TEMP_DISABLED|DISABLED)"`
+	StatusOK string `json:"status_ok,omitempty" valid:"in(0|1)"`
 }
 
 // GetUsersEmailAccountsResponse data struct
@@ -36,10 +36,59 @@ type GetUsersEmailAccountsResponse struct {
 	Port int `json:"port,omitempty"`
 }
 
+// CreateEmailAccountParams form values data struct.
+// Requires Email, Server, Username, UseSSL, Port, Type,
+// and either (if OAUTH) ProviderRefreshToken and ProviderConsumerKey,
+// or (if not OAUTH) Password, but not both.
+// Optional: StatusCallbackURL
+// 	https://context.io/docs/lite/users/email_accounts#post
+type CreateEmailAccountParams struct {
+	// Required:
+	Email    string `json:"email" valid:"required"`
+	Server   string `json:"server" valid:"required"`
+	Username string `json:"username" valid:"required"`
+	Type     string `json:"type" valid:"required,in(IMAP)"`
+	UseSSL   bool   `json:"use_ssl"`
+	Port     int    `json:"port" valid:"required"`
+
+	// Required for OAUTH:
+	ProviderRefreshToken cioutil.Secret `json:"provider_refresh_token,omitempty"`
+	ProviderConsumerKey  string         `json:"provider_consumer_key,omitempty"`
+
+	// Required for non-OAUTH:
+	Password cioutil.Secret `json:"password,omitempty"`
+
+	// Optional:
+	StatusCallbackURL string `json:"status_callback_url,omitempty" valid:"url"`
+}
+
+// ValidateParams implements cioutil.ParamsValidator, requiring either the OAUTH fields or Password, but not both
+func (params CreateEmailAccountParams) ValidateParams() []cioutil.FieldError {
+	oauth := params.OAuth()
+	password := len(params.Password) > 0
+
+	switch {
+	case oauth && password:
+		return []cioutil.FieldError{{Field: "password", Rule: "exclusive", Message: "must not be set along with provider_refresh_token or provider_consumer_key"}}
+	case !oauth && !password:
+		return []cioutil.FieldError{{Field: "password", Rule: "required", Message: "is required unless using provider_refresh_token and provider_consumer_key"}}
+	case oauth && len(params.ProviderRefreshToken) == 0:
+		return []cioutil.FieldError{{Field: "provider_refresh_token", Rule: "required", Message: "is required along with provider_consumer_key"}}
+	case oauth && len(params.ProviderConsumerKey) == 0:
+		return []cioutil.FieldError{{Field: "provider_consumer_key", Rule: "required", Message: "is required along with provider_refresh_token"}}
+	}
+	return nil
+}
+
+// OAuth returns true if the params authenticate with OAUTH rather than a Password
+func (params CreateEmailAccountParams) OAuth() bool {
+	return len(params.ProviderRefreshToken) > 0 || len(params.ProviderConsumerKey) > 0
+}
+
 // CreateEmailAccountResponse data struct
 // 	https://context.io/docs/lite/users/email_accounts#post
 type CreateEmailAccountResponse struct {
-	Status      string `json:"stats,omitempty"`
+	Status      string `json:"status,omitempty"`
 	Label       string `json:"label,omitempty"`
 	ResourceURL string `json:"resource_url,omitempty"`
 }
@@ -50,12 +99,12 @@ type CreateEmailAccountResponse struct {
 // 	https://context.io/docs/lite/users/email_accounts#id-post
 type ModifyUserEmailAccountParams struct {
 	// Optional:
-	Status               string `json:"status,omitempty"`
-	Password             string `json:"password,omitempty"`
-	ProviderRefreshToken string `json:"provider_refresh_token,omitempty"`
-	ProviderConsumerKey  string `json:"provider_consumer_key,omitempty"`
-	StatusCallbackURL    string `json:"status_callback_url,omitempty"`
-	ForceStatusCheck     bool   `json:"force_status_check,omitempty"`
+	Status               string         `json:"status,omitempty"`
+	Password             cioutil.Secret `json:"password,omitempty"`
+	ProviderRefreshToken cioutil.Secret `json:"provider_refresh_token,omitempty"`
+	ProviderConsumerKey  string         `json:"provider_consumer_key,omitempty"`
+	StatusCallbackURL    string         `json:"status_callback_url,omitempty" valid:"url"`
+	ForceStatusCheck     bool           `json:"force_status_check,omitempty"`
 }
 
 // ModifyEmailAccountResponse data struct
@@ -91,6 +140,11 @@ type StatusCallback struct {
 	Timestamp int `json:"timestamp,omitempty" valid:"required"`
 }
 
+// TimestampTime returns Timestamp as a time.Time, or the zero time.Time if not set.
+func (statusCallback StatusCallback) TimestampTime() time.Time {
+	return cioutil.UnixTime(statusCallback.Timestamp)
+}
+
 // GetUserEmailAccounts gets a list of email accounts assigned to a user.
 // queryValues may optionally contain Status, StatusOK
 // 	https://context.io/docs/lite/users/email_accounts#get
@@ -99,7 +153,8 @@ func (cioLite CioLite) GetUserEmailAccounts(userID string, queryValues GetUserEm
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts", userID),
+		Path:        "/users/{id}/email_accounts",
+		PathValues:  cioutil.PathValues{"id": userID},
 		QueryValues: queryValues,
 	}
 
@@ -119,8 +174,9 @@ func (cioLite CioLite) GetUserEmailAccount(userID string, label string) (GetUser
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/users/%s/email_accounts/%s", userID, label),
+		Method:     "GET",
+		Path:       "/users/{id}/email_accounts/{label}",
+		PathValues: cioutil.PathValues{"id": userID, "label": label},
 	}
 
 	// Make response
@@ -134,16 +190,16 @@ func (cioLite CioLite) GetUserEmailAccount(userID string, label string) (GetUser
 
 // CreateUserEmailAccount adds a mailbox to a given user.
 // formValues requires Email, Server, Username, UseSSL, Port, Type,
-// and (if OAUTH) ProviderRefreshToken and ProviderConsumerKey,
-// and (if not OAUTH) Password,
-// and may optionally contain StatusCallbackURL
+// and either (if OAUTH) ProviderRefreshToken and ProviderConsumerKey,
+// or (if not OAUTH) Password, and may optionally contain StatusCallbackURL
 // 	https://context.io/docs/lite/users/email_accounts#post
-func (cioLite CioLite) CreateUserEmailAccount(userID string, formValues CreateUserParams) (CreateEmailAccountResponse, error) {
+func (cioLite CioLite) CreateUserEmailAccount(userID string, formValues CreateEmailAccountParams) (CreateEmailAccountResponse, error) {
 
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s/email_accounts", userID),
+		Path:       "/users/{id}/email_accounts",
+		PathValues: cioutil.PathValues{"id": userID},
 		FormValues: formValues,
 	}
 
@@ -165,7 +221,8 @@ func (cioLite CioLite) ModifyUserEmailAccount(userID string, label string, formV
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s/email_accounts/%s", userID, label),
+		Path:       "/users/{id}/email_accounts/{label}",
+		PathValues: cioutil.PathValues{"id": userID, "label": label},
 		FormValues: formValues,
 	}
 
@@ -184,8 +241,9 @@ func (cioLite CioLite) DeleteUserEmailAccount(userID string, label string) (Dele
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "DELETE",
-		Path:   fmt.Sprintf("/users/%s/email_accounts/%s", userID, label),
+		Method:     "DELETE",
+		Path:       "/users/{id}/email_accounts/{label}",
+		PathValues: cioutil.PathValues{"id": userID, "label": label},
 	}
 
 	// Make response
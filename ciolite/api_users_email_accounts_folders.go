@@ -3,7 +3,6 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/email_accounts/folders
 
 import (
-	"fmt"
 	"net/url"
 
 	"github.com/contextio/contextio-go/cioutil"
@@ -34,6 +33,7 @@ type GetUsersEmailAccountFoldersResponse struct {
 // Optional: Delimiter
 // 	https://context.io/docs/lite/users/email_accounts/folders#id-get
 // 	https://context.io/docs/lite/users/email_accounts/folders#id-post
+// 	https://context.io/docs/lite/users/email_accounts/folders#id-delete
 // 	https://context.io/docs/lite/users/email_accounts/folders/messages/attachments#get
 // 	https://context.io/docs/lite/users/email_accounts/folders/messages/attachments#id-get
 // 	https://context.io/docs/lite/users/email_accounts/folders/messages/flags#get
@@ -51,6 +51,38 @@ type CreateEmailAccountFolderResponse struct {
 	Success bool `json:"success,omitempty"`
 }
 
+// ModifyUserEmailAccountFolderParams form values data struct.
+// Requires at least one of: NewName, Subscribed; and may optionally contain Delimiter.
+// 	https://context.io/docs/lite/users/email_accounts/folders#id-put
+type ModifyUserEmailAccountFolderParams struct {
+	// Requires at least one of:
+	NewName    string `json:"new_name,omitempty"`
+	Subscribed *bool  `json:"subscribed,omitempty"`
+
+	// Optional:
+	Delimiter string `json:"delimiter,omitempty"`
+}
+
+// ValidateParams implements cioutil.ParamsValidator, requiring a new name or subscription
+func (params ModifyUserEmailAccountFolderParams) ValidateParams() []cioutil.FieldError {
+	if len(params.NewName) == 0 && params.Subscribed == nil {
+		return []cioutil.FieldError{{Field: "new_name", Rule: "required", Message: "or subscribed is required"}}
+	}
+	return nil
+}
+
+// ModifyEmailAccountFolderResponse data struct
+// 	https://context.io/docs/lite/users/email_accounts/folders#id-put
+type ModifyEmailAccountFolderResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
+// DeleteEmailAccountFolderResponse data struct
+// 	https://context.io/docs/lite/users/email_accounts/folders#id-delete
+type DeleteEmailAccountFolderResponse struct {
+	Success bool `json:"success,omitempty"`
+}
+
 // GetUserEmailAccountsFolders gets a list of folders in an email account.
 // queryValues may optionally contain IncludeNamesOnly
 // 	https://context.io/docs/lite/users/email_accounts/folders#get
@@ -59,7 +91,8 @@ func (cioLite CioLite) GetUserEmailAccountsFolders(userID string, label string,
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders", userID, label),
+		Path:        "/users/{id}/email_accounts/{label}/folders",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label},
 		QueryValues: queryValues,
 	}
 
@@ -80,7 +113,8 @@ func (cioLite CioLite) GetUserEmailAccountFolder(userID string, label string, fo
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s", userID, label, url.QueryEscape(folder)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder)},
 		QueryValues: queryValues,
 	}
 
@@ -102,7 +136,8 @@ func (cioLite CioLite) CreateUserEmailAccountFolder(userID string, label string,
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s", userID, label, url.QueryEscape(folder)),
+		Path:       "/users/{id}/email_accounts/{label}/folders/{folder}",
+		PathValues: cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder)},
 		FormValues: formValues,
 	}
 
@@ -115,6 +150,51 @@ func (cioLite CioLite) CreateUserEmailAccountFolder(userID string, label string,
 	return response, err
 }
 
+// ModifyUserEmailAccountFolder renames a folder, and/or subscribes or unsubscribes it.
+// formValues requires at least one of NewName, Subscribed, and may optionally contain Delimiter
+// 	https://context.io/docs/lite/users/email_accounts/folders#id-put
+func (cioLite CioLite) ModifyUserEmailAccountFolder(userID string, label string, folder string, formValues ModifyUserEmailAccountFolderParams) (ModifyEmailAccountFolderResponse, error) {
+
+	// Make request
+	request := cioutil.ClientRequest{
+		Method:     "PUT",
+		Path:       "/users/{id}/email_accounts/{label}/folders/{folder}",
+		PathValues: cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder)},
+		FormValues: formValues,
+	}
+
+	// Make response
+	var response ModifyEmailAccountFolderResponse
+
+	// Request
+	err := cioLite.DoFormRequest(request, &response)
+
+	return response, err
+}
+
+// DeleteUserEmailAccountFolder deletes a folder, and any messages in it.
+// Use SafeDeleteUserEmailAccountFolder to keep the messages, or to delete subfolders too.
+// queryValues may optionally contain Delimiter
+// 	https://context.io/docs/lite/users/email_accounts/folders#id-delete
+func (cioLite CioLite) DeleteUserEmailAccountFolder(userID string, label string, folder string, queryValues EmailAccountFolderDelimiterParam) (DeleteEmailAccountFolderResponse, error) {
+
+	// Make request
+	request := cioutil.ClientRequest{
+		Method:      "DELETE",
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder)},
+		QueryValues: queryValues,
+	}
+
+	// Make response
+	var response DeleteEmailAccountFolderResponse
+
+	// Request
+	err := cioLite.DoFormRequest(request, &response)
+
+	return response, err
+}
+
 // SafeCreateUserEmailAccountFolder will safely check if a folder exists, and create it if it does not.
 // This function returns a bool representing whether it had to create a folder, and any errors it received.
 // queryValues may optionally contain Delimiter
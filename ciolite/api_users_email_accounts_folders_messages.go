@@ -5,8 +5,8 @@ package ciolite
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/contextio/contextio-go/cioutil"
 )
@@ -19,12 +19,12 @@ import (
 type GetUserEmailAccountsFolderMessageParams struct {
 	// Optional:
 	Delimiter    string `json:"delimiter,omitempty"`
-	BodyType     string `json:"body_type,omitempty"`
+	BodyType     string `json:"body_type,omitempty" valid:"in(text/plain|text/html)"`
 	IncludeBody  bool   `json:"include_body,omitempty"`
 	IncludeFlags bool   `json:"include_flags,omitempty"`
 
 	// IncludeHeaders can be "0", "1", or "raw"
-	IncludeHeaders string `json:"include_headers,omitempty"`
+	IncludeHeaders string `json:"include_headers,omitempty" valid:"in(0|1|raw)"`
 
 	// Optional for GetUserEmailAccountsFolderMessages (not used by GetUserEmailAccountFolderMessage):
 	Limit  int `json:"limit,omitempty"`
@@ -50,6 +50,12 @@ type GetUsersEmailAccountFolderMessagesResponse struct {
 
 	PersonInfo PersonInfo `json:"person_info,omitempty"`
 
+	// Headers are only returned if IncludeHeaders is "1" or "raw" (and are parsed from the raw headers if "raw")
+	Headers MessageHeaders `json:"headers,omitempty"`
+
+	// Flags are only returned if IncludeFlags
+	Flags MessageFlags `json:"flags,omitempty"`
+
 	Attachments []struct {
 		Type               string `json:"type,omitempty"`
 		FileName           string `json:"file_name,omitempty"`
@@ -74,6 +80,16 @@ type GetUsersEmailAccountFolderMessagesResponse struct {
 	ReceivedAt int `json:"received_at,omitempty"`
 }
 
+// SentAtTime returns SentAt as a time.Time, or the zero time.Time if not set.
+func (message GetUsersEmailAccountFolderMessagesResponse) SentAtTime() time.Time {
+	return cioutil.UnixTime(message.SentAt)
+}
+
+// ReceivedAtTime returns ReceivedAt as a time.Time, or the zero time.Time if not set.
+func (message GetUsersEmailAccountFolderMessagesResponse) ReceivedAtTime() time.Time {
+	return cioutil.UnixTime(message.ReceivedAt)
+}
+
 // PersonInfo data struct within GetUsersEmailAccountFolderMessagesResponse and WebhookMessageData
 // 	https://context.io/docs/lite/users/email_accounts/folders/messages#get
 // 	https://context.io/docs/lite/users/email_accounts/folders/messages#id-get
@@ -135,7 +151,7 @@ type GetUsersEmailAccountFolderMessageAddresses struct {
 // 	https://context.io/docs/lite/users/email_accounts/folders/messages#id-put
 type MoveUserEmailAccountFolderMessageParams struct {
 	// Required:
-	NewFolderID string `json:"new_folder_id"`
+	NewFolderID string `json:"new_folder_id" valid:"required"`
 	// Optional:
 	Delimiter string `json:"delimiter,omitempty"`
 }
@@ -155,7 +171,8 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessages(userID string, label s
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages", userID, label, url.QueryEscape(folder)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder)},
 		QueryValues: queryValues,
 	}
 
@@ -168,6 +185,28 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessages(userID string, label s
 	return response, err
 }
 
+// GetAllUserEmailAccountsFolderMessages pages through GetUserEmailAccountsFolderMessages (using Limit and Offset),
+// returning all messages in the folder.
+// queryValues may optionally contain Delimiter, IncludeBody, BodyType, IncludeHeaders, IncludeFlags,
+// and Limit (the page size, defaulting to 100)
+func (cioLite CioLite) GetAllUserEmailAccountsFolderMessages(userID string, label string, folder string, queryValues GetUserEmailAccountsFolderMessageParams) ([]GetUsersEmailAccountFolderMessagesResponse, error) {
+	if queryValues.Limit <= 0 {
+		queryValues.Limit = 100
+	}
+
+	var messages []GetUsersEmailAccountFolderMessagesResponse
+	for queryValues.Offset = 0; ; queryValues.Offset += queryValues.Limit {
+		page, err := cioLite.GetUserEmailAccountsFolderMessages(userID, label, folder, queryValues)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, page...)
+		if len(page) < queryValues.Limit {
+			return messages, nil
+		}
+	}
+}
+
 // GetUserEmailAccountFolderMessage gets file, contact and other information about a given email message.
 // queryValues may optionally contain Delimiter, IncludeBody, BodyType, IncludeHeaders, IncludeFlags
 // 	https://context.io/docs/lite/users/email_accounts/folders/messages#id-get
@@ -176,7 +215,8 @@ func (cioLite CioLite) GetUserEmailAccountFolderMessage(userID string, label str
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		QueryValues: queryValues,
 	}
 
@@ -197,7 +237,8 @@ func (cioLite CioLite) MoveUserEmailAccountFolderMessage(userID string, label st
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "PUT",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		QueryValues: queryValues,
 	}
 
@@ -3,7 +3,6 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/email_accounts/folders/messages/attachments
 
 import (
-	"fmt"
 	"net/url"
 
 	"github.com/contextio/contextio-go/cioutil"
@@ -32,7 +31,8 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessageAttachments(userID strin
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s/attachments", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/attachments",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		QueryValues: queryValues,
 	}
 
@@ -53,7 +53,8 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessageAttachment(userID string
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s/attachments/%s", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID), attachmentID),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/attachments/{attachment_id}",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID), "attachment_id": attachmentID},
 		QueryValues: queryValues,
 	}
 
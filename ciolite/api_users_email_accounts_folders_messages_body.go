@@ -3,7 +3,6 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/email_accounts/folders/messages/body
 
 import (
-	"fmt"
 	"net/url"
 
 	"github.com/contextio/contextio-go/cioutil"
@@ -15,7 +14,7 @@ import (
 type GetUserEmailAccountsFolderMessageBodyParams struct {
 	// Optional:
 	Delimiter string `json:"delimiter,omitempty"`
-	Type      string `json:"type,omitempty"`
+	Type      string `json:"type,omitempty" valid:"in(text/plain|text/html)"`
 }
 
 // GetUserEmailAccountsFolderMessageBodyResponse data struct
@@ -35,7 +34,8 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessageBody(userID string, labe
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s/body", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/body",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		QueryValues: queryValues,
 	}
 
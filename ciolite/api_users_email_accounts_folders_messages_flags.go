@@ -3,10 +3,12 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/email_accounts/folders/messages/flags
 
 import (
-	"fmt"
+	"context"
 	"net/url"
+	"strconv"
 
 	"github.com/contextio/contextio-go/cioutil"
+	"github.com/pkg/errors"
 )
 
 // GetUserEmailAccountsFolderMessageFlagsResponse data struct
@@ -14,12 +16,39 @@ import (
 type GetUserEmailAccountsFolderMessageFlagsResponse struct {
 	ResourceURL string `json:"resource_url,omitempty"`
 
-	Flags struct {
-		Read     bool `json:"read,omitempty"`
-		Answered bool `json:"answered,omitempty"`
-		Flagged  bool `json:"flagged,omitempty"`
-		Draft    bool `json:"draft,omitempty"`
-	} `json:"flags,omitempty"`
+	Flags MessageFlags `json:"flags,omitempty"`
+}
+
+// SetUserEmailAccountsFolderMessageFlagsParams form values data struct.
+// Requires at least one of Read, Answered, Flagged, Draft, Deleted (nil flags are left unchanged),
+// and may optionally contain Delimiter.
+// 	https://context.io/docs/lite/users/email_accounts/folders/messages/flags#post
+type SetUserEmailAccountsFolderMessageFlagsParams struct {
+	// Required (at least one):
+	Read     *bool `json:"seen,omitempty"`
+	Answered *bool `json:"answered,omitempty"`
+	Flagged  *bool `json:"flagged,omitempty"`
+	Draft    *bool `json:"draft,omitempty"`
+	Deleted  *bool `json:"deleted,omitempty"`
+
+	// Optional:
+	Delimiter string `json:"delimiter,omitempty"`
+}
+
+// ValidateParams implements cioutil.ParamsValidator, requiring at least one flag to be set or cleared
+func (params SetUserEmailAccountsFolderMessageFlagsParams) ValidateParams() []cioutil.FieldError {
+	if params.Read == nil && params.Answered == nil && params.Flagged == nil && params.Draft == nil && params.Deleted == nil {
+		return []cioutil.FieldError{{Field: "seen", Rule: "required", Message: "or another flag is required"}}
+	}
+	return nil
+}
+
+// SetUserEmailAccountsFolderMessageFlagsResponse data struct
+// 	https://context.io/docs/lite/users/email_accounts/folders/messages/flags#post
+type SetUserEmailAccountsFolderMessageFlagsResponse struct {
+	Success bool `json:"success,omitempty"`
+
+	Flags MessageFlags `json:"flags,omitempty"`
 }
 
 // GetUserEmailAccountsFolderMessageFlags returns the message flags.
@@ -30,7 +59,8 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessageFlags(userID string, lab
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s/flags", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/flags",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		QueryValues: queryValues,
 	}
 
@@ -42,3 +72,70 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessageFlags(userID string, lab
 
 	return response, err
 }
+
+// SetUserEmailAccountsFolderMessageFlags sets and clears the message flags.
+// formValues requires at least one of Read, Answered, Flagged, Draft, Deleted, and may optionally contain Delimiter
+// 	https://context.io/docs/lite/users/email_accounts/folders/messages/flags#post
+func (cioLite CioLite) SetUserEmailAccountsFolderMessageFlags(userID string, label string, folder string, messageID string, formValues SetUserEmailAccountsFolderMessageFlagsParams) (SetUserEmailAccountsFolderMessageFlagsResponse, error) {
+
+	// Make request
+	request := cioutil.ClientRequest{
+		Method:     "POST",
+		Path:       "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/flags",
+		PathValues: cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
+		FormValues: formValues,
+	}
+
+	// Make response
+	var response SetUserEmailAccountsFolderMessageFlagsResponse
+
+	// Request
+	err := cioLite.DoFormRequest(request, &response)
+
+	return response, err
+}
+
+// MessageFlagsResult is the result of setting the flags of one message with SetUserEmailAccountsFolderMessageFlagsBulk
+type MessageFlagsResult struct {
+	MessageID string
+	Flags     MessageFlags
+	Err       error
+}
+
+// SetUserEmailAccountsFolderMessageFlagsBulk sets and clears the flags of each message in the folder
+// with SetUserEmailAccountsFolderMessageFlags, continuing past failures.
+// Returns the result of each message, and an error if any failed or ctx was cancelled.
+func (cioLite CioLite) SetUserEmailAccountsFolderMessageFlagsBulk(ctx context.Context, userID string, label string, folder string, messageIDs []string, formValues SetUserEmailAccountsFolderMessageFlagsParams) ([]MessageFlagsResult, error) {
+	if err := cioutil.Validate(formValues); err != nil {
+		return nil, err
+	}
+	cioLite = cioLite.WithContext(ctx)
+
+	results := make([]MessageFlagsResult, 0, len(messageIDs))
+	failed := 0
+	for _, messageID := range messageIDs {
+		if err := ctx.Err(); err != nil {
+			return results, errors.Wrap(err, "CIO: Stopped setting message flags")
+		}
+
+		result := MessageFlagsResult{MessageID: messageID}
+		response, err := cioLite.SetUserEmailAccountsFolderMessageFlags(userID, label, folder, messageID, formValues)
+		switch {
+		case err != nil:
+			result.Err = err
+		case !response.Success:
+			result.Err = errors.New("CIO: Unable to set message flags")
+		default:
+			result.Flags = response.Flags
+		}
+		if result.Err != nil {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return results, errors.New("CIO: Unable to set flags of " + strconv.Itoa(failed) + " of " + strconv.Itoa(len(messageIDs)) + " messages")
+	}
+	return results, nil
+}
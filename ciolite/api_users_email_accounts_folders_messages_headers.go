@@ -3,7 +3,6 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/email_accounts/folders/messages/headers
 
 import (
-	"fmt"
 	"net/url"
 
 	"github.com/contextio/contextio-go/cioutil"
@@ -23,7 +22,8 @@ type GetUserEmailAccountsFolderMessageHeadersParams struct {
 type GetUserEmailAccountsFolderMessageHeadersResponse struct {
 	ResourceURL string `json:"resource_url,omitempty"`
 
-	Headers map[string][]string `json:"headers,omitempty"`
+	// Headers are parsed from the raw headers if Raw
+	Headers MessageHeaders `json:"headers,omitempty"`
 }
 
 // GetUserEmailAccountsFolderMessageHeaders gets the complete headers of a given email message.
@@ -34,7 +34,8 @@ func (cioLite CioLite) GetUserEmailAccountsFolderMessageHeaders(userID string, l
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:      "GET",
-		Path:        fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s/headers", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:        "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/headers",
+		PathValues:  cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		QueryValues: queryValues,
 	}
 
@@ -3,7 +3,6 @@ package ciolite
 // Api functions that support: https://context.io/docs/lite/users/email_accounts/folders/messages/read
 
 import (
-	"fmt"
 	"net/url"
 
 	"github.com/contextio/contextio-go/cioutil"
@@ -24,7 +23,8 @@ func (cioLite CioLite) MarkUserEmailAccountsFolderMessageRead(userID string, lab
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s/read", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:       "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/read",
+		PathValues: cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		FormValues: formValues,
 	}
 
@@ -45,7 +45,8 @@ func (cioLite CioLite) MarkUserEmailAccountsFolderMessageUnRead(userID string, l
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "DELETE",
-		Path:       fmt.Sprintf("/users/%s/email_accounts/%s/folders/%s/messages/%s/read", userID, label, url.QueryEscape(folder), url.QueryEscape(messageID)),
+		Path:       "/users/{id}/email_accounts/{label}/folders/{folder}/messages/{message_id}/read",
+		PathValues: cioutil.PathValues{"id": userID, "label": label, "folder": url.QueryEscape(folder), "message_id": url.QueryEscape(messageID)},
 		FormValues: formValues,
 	}
 
@@ -0,0 +1,90 @@
+package ciolite
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/contextio/contextio-go/cioutil"
+)
+
+// TestSimulatedCreateUserEmailAccount tests CreateUserEmailAccount with a simulated server
+func TestSimulatedCreateUserEmailAccount(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/users/user1/email_accounts", func(w http.ResponseWriter, r *http.Request) {
+		Must(r.ParseForm())
+		if r.PostForm.Get("use_ssl") != "1" || r.PostForm.Get("port") != "993" || r.PostForm.Get("provider_refresh_token") != "refresh" ||
+			len(r.PostForm["password"]) != 0 || len(r.PostForm["first_name"]) != 0 {
+			t.Error("Unexpected form values: ", r.PostForm)
+		}
+		_, err := io.WriteString(w, `{"status":"OK","label":"test::gmail","resource_url":"https://api.context.io/lite/users/user1/email_accounts/test%3A%3Agmail"}`)
+		Must(err)
+	})
+
+	params := CreateEmailAccountParams{
+		Email:                "test@gmail.com",
+		Server:               "imap.gmail.com",
+		Username:             "test@gmail.com",
+		Type:                 "IMAP",
+		UseSSL:               true,
+		Port:                 993,
+		ProviderRefreshToken: "refresh",
+		ProviderConsumerKey:  "key",
+	}
+
+	expected := CreateEmailAccountResponse{
+		Status:      "OK",
+		Label:       "test::gmail",
+		ResourceURL: "https://api.context.io/lite/users/user1/email_accounts/test%3A%3Agmail",
+	}
+
+	response, err := cioLite.CreateUserEmailAccount("user1", params)
+	if err != nil || !reflect.DeepEqual(response, expected) {
+		t.Error("Expected: ", expected, "; Got: ", response, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+}
+
+// TestCreateEmailAccountParamsValidation tests the mutually exclusive OAUTH and password fields
+func TestCreateEmailAccountParamsValidation(t *testing.T) {
+	t.Parallel()
+
+	base := CreateEmailAccountParams{Email: "test@example.com", Server: "imap.example.com", Username: "test", Type: "IMAP", Port: 143}
+
+	password := base
+	password.Password = "hunter2"
+	if err := cioutil.Validate(password); err != nil {
+		t.Error("Expected valid password params; Got: ", err)
+	}
+
+	tests := []struct {
+		refreshToken cioutil.Secret
+		consumerKey  string
+		password     cioutil.Secret
+		field        string
+		rule         string
+	}{
+		{"", "", "", "password", "required"},
+		{"refresh", "key", "hunter2", "password", "exclusive"},
+		{"", "key", "", "provider_refresh_token", "required"},
+		{"refresh", "", "", "provider_consumer_key", "required"},
+	}
+
+	for _, test := range tests {
+		params := base
+		params.ProviderRefreshToken, params.ProviderConsumerKey, params.Password = test.refreshToken, test.consumerKey, test.password
+
+		validationErr, ok := cioutil.AsValidationError(cioutil.Validate(params))
+		if !ok || len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != test.field || validationErr.Fields[0].Rule != test.rule {
+			t.Error("Expected ", test.field, " ", test.rule, " validation error; Got: ", validationErr)
+		}
+	}
+
+	if validationErr, ok := cioutil.AsValidationError(cioutil.Validate(CreateEmailAccountParams{Password: "hunter2"})); !ok || len(validationErr.Fields) != 5 {
+		t.Error("Expected required field validation errors; Got: ", validationErr)
+	}
+}
@@ -5,7 +5,7 @@ package ciolite
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
+	"time"
 
 	"github.com/contextio/contextio-go/cioutil"
 )
@@ -43,8 +43,8 @@ type GetUsersWebhooksResponse struct {
 // 	https://context.io/docs/lite/users/webhooks#post
 type CreateUserWebhookParams struct {
 	// Requires:
-	CallbackURL     string `json:"callback_url"`
-	FailureNotifURL string `json:"failure_notif_url"`
+	CallbackURL     string `json:"callback_url" valid:"required,url"`
+	FailureNotifURL string `json:"failure_notif_url" valid:"required,url"`
 
 	// Optional:
 	FilterTo           string `json:"filter_to,omitempty"`
@@ -57,7 +57,7 @@ type CreateUserWebhookParams struct {
 	FilterFolderAdded  string `json:"filter_folder_added,omitempty"`
 	FilterToDomain     string `json:"filter_to_domain,omitempty"`
 	FilterFromDomain   string `json:"filter_from_domain,omitempty"`
-	BodyType           string `json:"body_type,omitempty"`
+	BodyType           string `json:"body_type,omitempty" valid:"in(text/plain|text/html)"`
 	IncludeBody        bool   `json:"include_body,omitempty"`
 }
 
@@ -108,6 +108,11 @@ type WebhookCallback struct {
 	MessageData WebhookMessageData `json:"message_data,omitempty"`
 }
 
+// TimestampTime returns Timestamp as a time.Time, or the zero time.Time if not set.
+func (webhookCallback WebhookCallback) TimestampTime() time.Time {
+	return cioutil.UnixTime(webhookCallback.Timestamp)
+}
+
 // WebhookMessageData data struct within WebhookCallback
 // 	https://context.io/docs/lite/users/webhooks#callbacks
 type WebhookMessageData struct {
@@ -125,12 +130,8 @@ type WebhookMessageData struct {
 
 	PersonInfo PersonInfo `json:"person_info,omitempty"`
 
-	Flags struct {
-		Flagged  bool `json:"flagged,omitempty"`
-		Answered bool `json:"answered,omitempty"`
-		Draft    bool `json:"draft,omitempty"`
-		Seen     bool `json:"seen,omitempty"`
-	} `json:"flags,omitempty"`
+	// Flags.Read is the seen flag
+	Flags MessageFlags `json:"flags,omitempty"`
 
 	Sources []struct {
 		Label  string `json:"label,omitempty"`
@@ -163,6 +164,16 @@ type WebhookMessageData struct {
 	} `json:"files,omitempty"`
 }
 
+// DateTime returns Date as a time.Time, or the zero time.Time if not set.
+func (messageData WebhookMessageData) DateTime() time.Time {
+	return cioutil.UnixTime(messageData.Date)
+}
+
+// DateReceivedTime returns DateReceived as a time.Time, or the zero time.Time if not set.
+func (messageData WebhookMessageData) DateReceivedTime() time.Time {
+	return cioutil.UnixTime(messageData.DateReceived)
+}
+
 // WebhookMessageDataAddresses struct within WebhookMessageData
 // 	https://context.io/docs/lite/users/webhooks#callbacks
 type WebhookMessageDataAddresses struct {
@@ -221,8 +232,9 @@ func (cioLite CioLite) GetUserWebhooks(userID string) ([]GetUsersWebhooksRespons
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/users/%s/webhooks", userID),
+		Method:     "GET",
+		Path:       "/users/{id}/webhooks",
+		PathValues: cioutil.PathValues{"id": userID},
 	}
 
 	// Make response
@@ -240,8 +252,9 @@ func (cioLite CioLite) GetUserWebhook(userID string, webhookID string) (GetUsers
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "GET",
-		Path:   fmt.Sprintf("/users/%s/webhooks/%s", userID, webhookID),
+		Method:     "GET",
+		Path:       "/users/{id}/webhooks/{webhook_id}",
+		PathValues: cioutil.PathValues{"id": userID, "webhook_id": webhookID},
 	}
 
 	// Make response
@@ -264,7 +277,8 @@ func (cioLite CioLite) CreateUserWebhook(userID string, formValues CreateUserWeb
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s/webhooks", userID),
+		Path:       "/users/{id}/webhooks",
+		PathValues: cioutil.PathValues{"id": userID},
 		FormValues: formValues,
 	}
 
@@ -285,7 +299,8 @@ func (cioLite CioLite) ModifyUserWebhook(userID string, webhookID string, formVa
 	// Make request
 	request := cioutil.ClientRequest{
 		Method:     "POST",
-		Path:       fmt.Sprintf("/users/%s/webhooks/%s", userID, webhookID),
+		Path:       "/users/{id}/webhooks/{webhook_id}",
+		PathValues: cioutil.PathValues{"id": userID, "webhook_id": webhookID},
 		FormValues: formValues,
 	}
 
@@ -304,8 +319,9 @@ func (cioLite CioLite) DeleteUserWebhookAccount(userID string, webhookID string)
 
 	// Make request
 	request := cioutil.ClientRequest{
-		Method: "DELETE",
-		Path:   fmt.Sprintf("/users/%s/webhooks/%s", userID, webhookID),
+		Method:     "DELETE",
+		Path:       "/users/{id}/webhooks/{webhook_id}",
+		PathValues: cioutil.PathValues{"id": userID, "webhook_id": webhookID},
 	}
 
 	// Make response
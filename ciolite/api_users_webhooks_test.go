@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 )
 
 // TestReceivingWebhookEmptyAddresses tests receiving and parsing WebhookCallback with empty addresses field
@@ -105,6 +106,12 @@ func TestSimulatedReceivingWebhookFullAddresses(t *testing.T) {
 		t.Error("Expected MessageData.Folders: ", "[Inbox]", "; Got: ", fullAddresses.MessageData.Folders)
 	}
 
+	if !fullAddresses.MessageData.DateTime().Equal(time.Unix(1446919784, 0)) ||
+		!fullAddresses.MessageData.DateReceivedTime().Equal(time.Unix(1446919783, 0)) ||
+		!fullAddresses.TimestampTime().Equal(time.Unix(1467254202, 0)) {
+		t.Error("Expected MessageData times to match timestamps; Got: ", fullAddresses.MessageData.DateTime(), fullAddresses.MessageData.DateReceivedTime())
+	}
+
 	type address struct {
 		Email string `json:"email,omitempty"`
 		Name  string `json:"name,omitempty"`
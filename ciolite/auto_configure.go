@@ -0,0 +1,166 @@
+package ciolite
+
+// Auto-configuration that supports: https://context.io/docs/lite/discovery
+// and: https://context.io/docs/lite/oauth_providers
+
+import (
+	"strings"
+
+	"github.com/contextio/contextio-go/cioutil"
+	"github.com/pkg/errors"
+)
+
+// DiscoveryOAuthProviderTypes maps the Type of a GetDiscoveryResponse to the Type of the
+// OAuth provider that must be registered (with CreateOAuthProvider) to connect with OAuth.
+var DiscoveryOAuthProviderTypes = map[string]string{
+	"gmail":         "GMAIL_OAUTH2",
+	"googleapps":    "GMAIL_OAUTH2",
+	"msliveconnect": "MSLIVECONNECT_OAUTH2",
+}
+
+// UnsupportedReason is the reason an email address can not be auto-configured
+type UnsupportedReason string
+
+const (
+	// UnsupportedNotFound means discovery could not find settings for the email address
+	UnsupportedNotFound UnsupportedReason = "not_found"
+
+	// UnsupportedNoIMAPServer means discovery did not return an IMAP server and port
+	UnsupportedNoIMAPServer UnsupportedReason = "no_imap_server"
+
+	// UnsupportedNoOAuthProvider means the email address requires OAuth,
+	// but no matching OAuth provider is registered (and password fallback is not allowed)
+	UnsupportedNoOAuthProvider UnsupportedReason = "no_oauth_provider"
+)
+
+// UnsupportedEmailError is the error returned by AutoConfigureEmailAccount when the email address is unsupported
+type UnsupportedEmailError struct {
+	Email  string
+	Reason UnsupportedReason
+
+	// Detail is any error message from discovery, or the OAuth provider type that is missing
+	Detail string
+}
+
+// Error returns the reason the email address is unsupported
+func (e UnsupportedEmailError) Error() string {
+	message := "CIO: Unsupported email address " + e.Email + ": " + string(e.Reason)
+	if len(e.Detail) > 0 {
+		message += " (" + e.Detail + ")"
+	}
+	return message
+}
+
+// AsUnsupportedEmailError returns the UnsupportedEmailError if err is (or was caused by) one
+func AsUnsupportedEmailError(err error) (UnsupportedEmailError, bool) {
+	unsupportedErr, ok := errors.Cause(err).(UnsupportedEmailError)
+	return unsupportedErr, ok
+}
+
+// AutoConfigureOptions data struct.
+// Optional: AllowPasswordFallback (use a password if the email address supports OAuth,
+// but no matching OAuth provider is registered).
+type AutoConfigureOptions struct {
+	AllowPasswordFallback bool
+}
+
+// AutoConfiguration is the result of AutoConfigureEmailAccount: the discovered settings,
+// and whether to connect with OAuth (using OAuthProvider) or with a password.
+type AutoConfiguration struct {
+	Email         string
+	Discovery     GetDiscoveryResponse
+	OAuth         bool
+	OAuthProvider GetOAuthProvidersResponse
+}
+
+// AutoConfigureEmailAccount runs discovery for the email address, and chooses OAuth or password
+// authentication based on discovery and the registered OAuth providers.
+// Returns an UnsupportedEmailError if the email address can not be connected.
+func (cioLite CioLite) AutoConfigureEmailAccount(email string, options AutoConfigureOptions) (AutoConfiguration, error) {
+
+	discovery, err := cioLite.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: email})
+	if err != nil {
+		return AutoConfiguration{}, err
+	}
+
+	config := AutoConfiguration{Email: email, Discovery: discovery}
+
+	if !discovery.Found {
+		return config, UnsupportedEmailError{Email: email, Reason: UnsupportedNotFound, Detail: discovery.Value}
+	}
+	if len(discovery.IMAP.Server) == 0 || discovery.IMAP.Port == 0 {
+		return config, UnsupportedEmailError{Email: email, Reason: UnsupportedNoIMAPServer, Detail: discovery.Value}
+	}
+	if !discovery.IMAP.OAuth {
+		return config, nil
+	}
+
+	// OAuth requires a registered provider of the matching type
+	providerType, known := DiscoveryOAuthProviderTypes[strings.ToLower(discovery.Type)]
+	if known {
+		providers, err := cioLite.GetOAuthProviders()
+		if err != nil {
+			return config, err
+		}
+		for _, provider := range providers {
+			if strings.EqualFold(provider.Type, providerType) {
+				config.OAuth = true
+				config.OAuthProvider = provider
+				return config, nil
+			}
+		}
+	}
+
+	if options.AllowPasswordFallback {
+		return config, nil
+	}
+	if !known {
+		providerType = discovery.Type
+	}
+	return config, UnsupportedEmailError{Email: email, Reason: UnsupportedNoOAuthProvider, Detail: providerType}
+}
+
+// CreateEmailAccountParams returns the params for CreateUserEmailAccount, requiring only the
+// credential: the provider refresh token if OAuth, or the password otherwise.
+func (config AutoConfiguration) CreateEmailAccountParams(credential string) CreateEmailAccountParams {
+	params := CreateEmailAccountParams{
+		Email:    config.Email,
+		Server:   config.Discovery.IMAP.Server,
+		Username: config.username(),
+		Type:     "IMAP",
+		UseSSL:   config.Discovery.IMAP.UseSSL,
+		Port:     config.Discovery.IMAP.Port,
+	}
+	if config.OAuth {
+		params.ProviderRefreshToken = cioutil.Secret(credential)
+		params.ProviderConsumerKey = config.OAuthProvider.ProviderConsumerKey
+	} else {
+		params.Password = cioutil.Secret(credential)
+	}
+	return params
+}
+
+// CreateUserParams returns the params for CreateUser (creating the user along with the email account),
+// requiring only the credential: the provider refresh token if OAuth, or the password otherwise.
+func (config AutoConfiguration) CreateUserParams(credential string) CreateUserParams {
+	params := config.CreateEmailAccountParams(credential)
+	return CreateUserParams{
+		Email:                params.Email,
+		Server:               params.Server,
+		Username:             params.Username,
+		Type:                 params.Type,
+		UseSSL:               params.UseSSL,
+		Port:                 params.Port,
+		ProviderRefreshToken: params.ProviderRefreshToken,
+		ProviderConsumerKey:  params.ProviderConsumerKey,
+		Password:             params.Password,
+	}
+}
+
+// username returns the discovered username, or the email address if none
+func (config AutoConfiguration) username() string {
+	if len(config.Discovery.IMAP.Username) > 0 {
+		return config.Discovery.IMAP.Username
+	}
+	return config.Email
+}
@@ -0,0 +1,73 @@
+package ciolite
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// TestSimulatedAutoConfigureEmailAccount tests choosing OAuth or password from discovery and registered providers
+func TestSimulatedAutoConfigureEmailAccount(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	discoveries := map[string]string{
+		"test@gmail.com":   `{"email":"test@gmail.com","type":"gmail","found":true,"imap":{"server":"imap.gmail.com","username":"test@gmail.com","use_ssl":true,"oauth":true,"port":993}}`,
+		"test@hotmail.com": `{"email":"test@hotmail.com","type":"msliveconnect","found":true,"imap":{"server":"imap-mail.outlook.com","use_ssl":true,"oauth":true,"port":993}}`,
+		"test@yahoo.com":   `{"email":"test@yahoo.com","type":"generic","found":true,"imap":{"server":"imap.mail.yahoo.com","username":"test@yahoo.com","use_ssl":true,"port":993}}`,
+		"test@nowhere.com": `{"email":"test@nowhere.com","found":false,"value":"Unable to find settings"}`,
+	}
+	mux.HandleFunc("/discovery", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, discoveries[r.URL.Query().Get("email")])
+		Must(err)
+	})
+	mux.HandleFunc("/oauth_providers", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `[{"type":"GMAIL_OAUTH2","provider_consumer_key":"gmailkey"}]`)
+		Must(err)
+	})
+
+	// OAuth with a registered provider
+	config, err := cioLite.AutoConfigureEmailAccount("test@gmail.com", AutoConfigureOptions{})
+	expected := CreateEmailAccountParams{
+		Email:                "test@gmail.com",
+		Server:               "imap.gmail.com",
+		Username:             "test@gmail.com",
+		Type:                 "IMAP",
+		UseSSL:               true,
+		Port:                 993,
+		ProviderRefreshToken: "refresh",
+		ProviderConsumerKey:  "gmailkey",
+	}
+	if params := config.CreateEmailAccountParams("refresh"); err != nil || !config.OAuth || !reflect.DeepEqual(params, expected) {
+		t.Error("Expected: ", expected, "; Got: ", params, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+	if params := config.CreateUserParams("refresh"); params.ProviderConsumerKey != "gmailkey" || params.Server != "imap.gmail.com" {
+		t.Error("Expected matching CreateUserParams; Got: ", params)
+	}
+
+	// Password
+	config, err = cioLite.AutoConfigureEmailAccount("test@yahoo.com", AutoConfigureOptions{})
+	if params := config.CreateEmailAccountParams("hunter2"); err != nil || config.OAuth || params.Password != "hunter2" || params.Port != 993 {
+		t.Error("Expected password params; Got: ", params, "; With Error: ", err)
+	}
+
+	// OAuth without a registered provider
+	_, err = cioLite.AutoConfigureEmailAccount("test@hotmail.com", AutoConfigureOptions{})
+	if unsupportedErr, ok := AsUnsupportedEmailError(err); !ok || unsupportedErr.Reason != UnsupportedNoOAuthProvider || unsupportedErr.Detail != "MSLIVECONNECT_OAUTH2" {
+		t.Error("Expected no oauth provider; Got: ", err)
+	}
+
+	config, err = cioLite.AutoConfigureEmailAccount("test@hotmail.com", AutoConfigureOptions{AllowPasswordFallback: true})
+	if params := config.CreateEmailAccountParams("hunter2"); err != nil || config.OAuth || params.Username != "test@hotmail.com" || params.Password != "hunter2" {
+		t.Error("Expected password fallback params; Got: ", params, "; With Error: ", err)
+	}
+
+	// Not found
+	_, err = cioLite.AutoConfigureEmailAccount("test@nowhere.com", AutoConfigureOptions{})
+	if unsupportedErr, ok := AsUnsupportedEmailError(err); !ok || unsupportedErr.Reason != UnsupportedNotFound || unsupportedErr.Detail != "Unable to find settings" {
+		t.Error("Expected not found; Got: ", err)
+	}
+}
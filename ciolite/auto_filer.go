@@ -0,0 +1,534 @@
+package ciolite
+
+// Auto-filing rules that support: https://context.io/docs/lite/users/email_accounts/folders/messages
+// and: https://context.io/docs/lite/users/webhooks#callbacks
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AutoFilingAction is the action of an AutoFilingStep
+type AutoFilingAction string
+
+const (
+	// AutoFilingMarkRead marks a message as read
+	AutoFilingMarkRead AutoFilingAction = "mark_read"
+
+	// AutoFilingCreateFolder creates the target folder of moves, with SafeCreateUserEmailAccountFolder
+	AutoFilingCreateFolder AutoFilingAction = "create_folder"
+
+	// AutoFilingMove moves a message to the target folder
+	AutoFilingMove AutoFilingAction = "move"
+)
+
+// MessageRule is an auto-filing rule of an AutoFiler. A message matches the rule if it matches every condition set,
+// and a rule must have at least one condition and one action.
+type MessageRule struct {
+	Name string
+
+	// Conditions:
+
+	// From matches the sender, and Recipients any To, Cc, or Bcc address, by email address (with an "@"),
+	// or by domain and its subdomains (without an "@"), case-insensitively.
+	From       []string
+	Recipients []string
+
+	// SubjectContains matches subjects containing any of the strings, case-insensitively
+	SubjectContains []string
+
+	// ListIDs matches mailing lists by List-Id, and MailingLists matches any message from a mailing list
+	ListIDs      []string
+	MailingLists bool
+
+	// HasAttachments matches messages with (if true) or without (if false) attachments
+	HasAttachments *bool
+
+	// OlderThan matches messages received longer ago than the duration
+	OlderThan time.Duration
+
+	// Match matches messages with a custom func
+	Match func(message FilingMessage) bool
+
+	// Actions:
+
+	// MoveTo moves the message to the folder, creating the folder if it does not exist
+	MoveTo string
+
+	// MarkRead marks the message as read
+	MarkRead bool
+}
+
+// FilingMessage is a message checked against the rules of an AutoFiler,
+// from GetUserEmailAccountsFolderMessages (with NewFilingMessage) or a WebhookCallback (with NewWebhookFilingMessage).
+type FilingMessage struct {
+	// MessageID is the EmailMessageID (or MessageID) of the message, and Label and Folder where it is
+	MessageID string
+	Label     string
+	Folder    string
+
+	Subject    string
+	From       SenderIdentity
+	Recipients []string
+
+	// List is only parsed from GetUserEmailAccountsFolderMessages, as webhooks do not include the list headers
+	List MailingList
+
+	Attachments int
+	Date        time.Time
+	Read        bool
+}
+
+// NewFilingMessage returns the FilingMessage of a message in a folder.
+// The message must be listed with IncludeFlags for its read flag.
+func NewFilingMessage(label string, folder string, message GetUsersEmailAccountFolderMessagesResponse) FilingMessage {
+	filing := FilingMessage{
+		MessageID:   message.EmailMessageID,
+		Label:       label,
+		Folder:      folder,
+		Subject:     message.Subject,
+		List:        ParseMailingList(message),
+		Attachments: len(message.Attachments),
+		Date:        message.ReceivedAtTime(),
+		Read:        message.Flags.Read,
+	}
+	if len(filing.MessageID) == 0 {
+		filing.MessageID = message.MessageID
+	}
+	if filing.Date.IsZero() {
+		filing.Date = message.SentAtTime()
+	}
+	filing.From = filing.List.Sender
+
+	for _, to := range message.Addresses.To {
+		filing.Recipients = append(filing.Recipients, strings.ToLower(to.Email))
+	}
+	for _, cc := range message.Addresses.Cc {
+		filing.Recipients = append(filing.Recipients, strings.ToLower(cc.Email))
+	}
+	for _, bcc := range message.Addresses.Bcc {
+		filing.Recipients = append(filing.Recipients, strings.ToLower(bcc.Email))
+	}
+	return filing
+}
+
+// NewWebhookFilingMessage returns the FilingMessage of the message of a webhook callback,
+// in the first of its email accounts (or sources). Returns an error if the callback has no message.
+func NewWebhookFilingMessage(callback WebhookCallback) (FilingMessage, error) {
+	data := callback.MessageData
+	filing := FilingMessage{
+		MessageID: data.EmailMessageID,
+		Subject:   data.Subject,
+		From:      newSenderIdentity(data.Addresses.From.Email, data.Addresses.From.Name),
+		Date:      data.DateReceivedTime(),
+		Read:      data.Flags.Read,
+	}
+	if len(filing.MessageID) == 0 {
+		filing.MessageID = data.MessageID
+	}
+	if filing.Date.IsZero() {
+		filing.Date = data.DateTime()
+	}
+
+	if len(data.EmailAccounts) > 0 {
+		filing.Label, filing.Folder = data.EmailAccounts[0].Label, data.EmailAccounts[0].Folder
+	} else if len(data.Sources) > 0 {
+		filing.Label, filing.Folder = data.Sources[0].Label, data.Sources[0].Folder
+	}
+	if len(filing.MessageID) == 0 || len(filing.Label) == 0 || len(filing.Folder) == 0 {
+		return filing, errors.New("CIO: Webhook callback has no message to file")
+	}
+
+	for _, to := range data.Addresses.To {
+		filing.Recipients = append(filing.Recipients, strings.ToLower(to.Email))
+	}
+	for _, cc := range data.Addresses.Cc {
+		filing.Recipients = append(filing.Recipients, strings.ToLower(cc.Email))
+	}
+	for _, bcc := range data.Addresses.Bcc {
+		filing.Recipients = append(filing.Recipients, strings.ToLower(bcc.Email))
+	}
+	for _, file := range data.Files {
+		if !file.IsEmbedded {
+			filing.Attachments++
+		}
+	}
+	return filing, nil
+}
+
+// Matches returns true if the message matches every condition of the rule
+func (rule MessageRule) Matches(message FilingMessage, now time.Time) bool {
+	if !rule.hasCondition() {
+		return false
+	}
+	if len(rule.From) > 0 && !matchesAddress(rule.From, message.From.Email) {
+		return false
+	}
+	if len(rule.Recipients) > 0 {
+		matched := false
+		for _, recipient := range message.Recipients {
+			matched = matched || matchesAddress(rule.Recipients, recipient)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.SubjectContains) > 0 {
+		matched := false
+		subject := strings.ToLower(message.Subject)
+		for _, contains := range rule.SubjectContains {
+			matched = matched || strings.Contains(subject, strings.ToLower(contains))
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.ListIDs) > 0 && !containsFoldString(rule.ListIDs, message.List.ID) {
+		return false
+	}
+	if rule.MailingLists && !message.List.IsList() {
+		return false
+	}
+	if rule.HasAttachments != nil && *rule.HasAttachments != (message.Attachments > 0) {
+		return false
+	}
+	if rule.OlderThan > 0 && (message.Date.IsZero() || now.Sub(message.Date) <= rule.OlderThan) {
+		return false
+	}
+	if rule.Match != nil && !rule.Match(message) {
+		return false
+	}
+	return true
+}
+
+// hasCondition returns true if any condition of the rule is set
+func (rule MessageRule) hasCondition() bool {
+	return len(rule.From) > 0 || len(rule.Recipients) > 0 || len(rule.SubjectContains) > 0 || len(rule.ListIDs) > 0 ||
+		rule.MailingLists || rule.HasAttachments != nil || rule.OlderThan > 0 || rule.Match != nil
+}
+
+// AutoFiler files messages by the first of its Rules that each message matches,
+// either for every message in the folders of an email account with Scan, or for each incoming message with HandleWebhook.
+// Actions are planned before any are applied, and with DryRun are only planned.
+type AutoFiler struct {
+	CioLite CioLite
+
+	// Required: checked in order, the first matching rule filing the message
+	Rules []MessageRule
+
+	// Optional: plan the actions that would be applied, without applying them
+	DryRun bool
+
+	// Optional: the Delimiter of folder names given to CIO
+	Delimiter string
+
+	// Optional: the Limit of each GetAllUserEmailAccountsFolderMessages page (defaults to 100)
+	PageSize int
+
+	// Optional: the current time, to check OlderThan against (defaults to time.Now)
+	Now func() time.Time
+}
+
+// AutoFilingPlan is the plan of an AutoFiler Scan or HandleWebhook
+type AutoFilingPlan struct {
+	DryRun bool
+
+	// Number of messages checked, and matched by a rule
+	Checked int
+	Matched int
+
+	// Steps that were applied, or would be applied if DryRun
+	Steps []AutoFilingStep
+}
+
+// AutoFilingStep is an action of an AutoFilingPlan. Target is the folder created or moved to,
+// and Err is any error applying it (or a previous step it depended on).
+type AutoFilingStep struct {
+	Action AutoFilingAction
+	Rule   string
+
+	UserID    string
+	Label     string
+	Folder    string
+	MessageID string
+	Subject   string
+	Target    string
+
+	Applied bool
+	Err     error
+}
+
+// Applied returns the number of steps that were applied
+func (plan AutoFilingPlan) Applied() int {
+	applied := 0
+	for _, step := range plan.Steps {
+		if step.Applied {
+			applied++
+		}
+	}
+	return applied
+}
+
+// Failed returns the steps that could not be applied
+func (plan AutoFilingPlan) Failed() []AutoFilingStep {
+	var failed []AutoFilingStep
+	for _, step := range plan.Steps {
+		if step.Err != nil {
+			failed = append(failed, step)
+		}
+	}
+	return failed
+}
+
+// Rule returns the first rule the message matches, and false if it matches none
+func (filer AutoFiler) Rule(message FilingMessage) (MessageRule, bool) {
+	now := filer.now()
+	for _, rule := range filer.Rules {
+		if rule.Matches(message, now) {
+			return rule, true
+		}
+	}
+	return MessageRule{}, false
+}
+
+// Scan files every message in the folders of an email account (defaults to INBOX and the other folders without
+// a SymbolicName from GetUserEmailAccountsFolders, so special folders such as \Sent, \Trash, \Drafts, and \Junk are not scanned).
+// Errors applying a step are recorded in the plan, while errors listing folders or messages stop the scan
+// before any step is applied, and are returned along with the plan so far.
+func (filer AutoFiler) Scan(ctx context.Context, userID string, label string, folders []string) (AutoFilingPlan, error) {
+	plan := AutoFilingPlan{DryRun: filer.DryRun}
+	if err := filer.validate(); err != nil {
+		return plan, err
+	}
+	cioLite := filer.CioLite.WithContext(ctx)
+
+	existing, regular, err := filer.folders(cioLite, userID, label)
+	if err != nil {
+		return plan, err
+	}
+	if len(folders) == 0 {
+		folders = regular
+	}
+
+	queryValues := GetUserEmailAccountsFolderMessageParams{Delimiter: filer.Delimiter, IncludeFlags: true, Limit: filer.PageSize}
+
+	// List every message before applying any step, as moving messages changes the offsets of the rest
+	for _, folder := range folders {
+		messages, err := cioLite.GetAllUserEmailAccountsFolderMessages(userID, label, folder, queryValues)
+		if ctx.Err() != nil {
+			return plan, errors.Wrap(ctx.Err(), "CIO: Stopped auto-filing")
+		}
+		if err != nil {
+			return plan, errors.Wrap(err, "CIO: Unable to list messages in folder "+folder)
+		}
+		for _, message := range messages {
+			filer.plan(&plan, userID, NewFilingMessage(label, folder, message), existing)
+		}
+	}
+
+	return filer.apply(ctx, cioLite, plan)
+}
+
+// HandleWebhook files the message of a webhook callback, for the user of its AccountID.
+// If any rule matches mailing lists, the message is first got with GetUserEmailAccountFolderMessage for its list headers.
+func (filer AutoFiler) HandleWebhook(ctx context.Context, callback WebhookCallback) (AutoFilingPlan, error) {
+	plan := AutoFilingPlan{DryRun: filer.DryRun}
+	if err := filer.validate(); err != nil {
+		return plan, err
+	}
+	cioLite := filer.CioLite.WithContext(ctx)
+	userID := callback.AccountID
+
+	message, err := NewWebhookFilingMessage(callback)
+	if err != nil {
+		return plan, err
+	}
+
+	if filer.matchesLists() {
+		full, err := cioLite.GetUserEmailAccountFolderMessage(userID, message.Label, message.Folder, message.MessageID,
+			GetUserEmailAccountsFolderMessageParams{Delimiter: filer.Delimiter, IncludeFlags: true})
+		if err != nil {
+			return plan, errors.Wrap(err, "CIO: Unable to get message "+message.MessageID)
+		}
+		message = NewFilingMessage(message.Label, message.Folder, full)
+	}
+
+	existing, _, err := filer.folders(cioLite, userID, message.Label)
+	if err != nil {
+		return plan, err
+	}
+	filer.plan(&plan, userID, message, existing)
+
+	return filer.apply(ctx, cioLite, plan)
+}
+
+// plan adds the steps filing the message to the plan.
+// existing is the set of folders that exist (or are planned to be created).
+func (filer AutoFiler) plan(plan *AutoFilingPlan, userID string, message FilingMessage, existing map[string]bool) {
+	plan.Checked++
+
+	rule, ok := filer.Rule(message)
+	if !ok {
+		return
+	}
+	plan.Matched++
+
+	step := AutoFilingStep{
+		Rule:      rule.Name,
+		UserID:    userID,
+		Label:     message.Label,
+		Folder:    message.Folder,
+		MessageID: message.MessageID,
+		Subject:   message.Subject,
+	}
+
+	// Mark read before moving, while the message is still in its folder
+	if rule.MarkRead && !message.Read {
+		step.Action = AutoFilingMarkRead
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	if len(rule.MoveTo) > 0 && rule.MoveTo != message.Folder {
+		step.Target = rule.MoveTo
+		if !existing[rule.MoveTo] {
+			existing[rule.MoveTo] = true
+			createStep := step
+			createStep.Action, createStep.Folder, createStep.MessageID, createStep.Subject = AutoFilingCreateFolder, "", "", ""
+			plan.Steps = append(plan.Steps, createStep)
+		}
+		step.Action = AutoFilingMove
+		plan.Steps = append(plan.Steps, step)
+	}
+}
+
+// apply applies the steps of the plan (unless DryRun), skipping the steps of messages
+// (and moves to folders) whose earlier steps failed. Returns an error if any step failed or ctx was cancelled.
+func (filer AutoFiler) apply(ctx context.Context, cioLite CioLite, plan AutoFilingPlan) (AutoFilingPlan, error) {
+	if filer.DryRun {
+		return plan, nil
+	}
+
+	delimiterParam := EmailAccountFolderDelimiterParam{Delimiter: filer.Delimiter}
+	failedMessages := make(map[string]bool)
+	failedFolders := make(map[string]bool)
+	failed := 0
+
+	for i := range plan.Steps {
+		if err := ctx.Err(); err != nil {
+			return plan, errors.Wrap(err, "CIO: Stopped auto-filing")
+		}
+
+		step := &plan.Steps[i]
+		messageKey := step.Label + "\x00" + step.Folder + "\x00" + step.MessageID
+		folderKey := step.Label + "\x00" + step.Target
+
+		switch {
+		case step.Action != AutoFilingCreateFolder && failedMessages[messageKey]:
+			step.Err = errors.New("CIO: Skipped after an earlier step for message " + step.MessageID + " failed")
+		case step.Action == AutoFilingMove && failedFolders[folderKey]:
+			step.Err = errors.New("CIO: Skipped after creating folder " + step.Target + " failed")
+		case step.Action == AutoFilingMarkRead:
+			var response UserEmailAccountsFolderMessageReadResponse
+			response, step.Err = cioLite.MarkUserEmailAccountsFolderMessageRead(step.UserID, step.Label, step.Folder, step.MessageID, delimiterParam)
+			if step.Err == nil && !response.Success {
+				step.Err = errors.New("CIO: Unable to mark message " + step.MessageID + " read")
+			}
+		case step.Action == AutoFilingCreateFolder:
+			_, step.Err = cioLite.SafeCreateUserEmailAccountFolder(step.UserID, step.Label, step.Target, delimiterParam)
+		case step.Action == AutoFilingMove:
+			var response MoveUserEmailAccountFolderMessageResponse
+			response, step.Err = cioLite.MoveUserEmailAccountFolderMessage(step.UserID, step.Label, step.Folder, step.MessageID,
+				MoveUserEmailAccountFolderMessageParams{NewFolderID: step.Target, Delimiter: filer.Delimiter})
+			if step.Err == nil && !response.Success {
+				step.Err = errors.New("CIO: Unable to move message " + step.MessageID + " to folder " + step.Target)
+			}
+		}
+
+		step.Applied = step.Err == nil
+		if step.Err != nil {
+			failed++
+			if step.Action == AutoFilingCreateFolder {
+				failedFolders[folderKey] = true
+			} else {
+				failedMessages[messageKey] = true
+			}
+		}
+	}
+
+	if failed > 0 {
+		return plan, errors.Errorf("CIO: Unable to apply %d of %d auto-filing steps", failed, len(plan.Steps))
+	}
+	return plan, nil
+}
+
+// validate returns an error if any rule has no condition or no action
+func (filer AutoFiler) validate() error {
+	for i, rule := range filer.Rules {
+		if !rule.hasCondition() {
+			return errors.Errorf("CIO: Auto-filing rule %d (%s) has no conditions", i, rule.Name)
+		}
+		if len(rule.MoveTo) == 0 && !rule.MarkRead {
+			return errors.Errorf("CIO: Auto-filing rule %d (%s) has no actions", i, rule.Name)
+		}
+	}
+	return nil
+}
+
+// matchesLists returns true if any rule has a mailing list condition
+func (filer AutoFiler) matchesLists() bool {
+	for _, rule := range filer.Rules {
+		if len(rule.ListIDs) > 0 || rule.MailingLists {
+			return true
+		}
+	}
+	return false
+}
+
+// folders returns the set of folders in the email account,
+// and the sorted names of the regular folders (INBOX and those without a SymbolicName)
+func (filer AutoFiler) folders(cioLite CioLite, userID string, label string) (map[string]bool, []string, error) {
+	folders, err := cioLite.GetUserEmailAccountsFolders(userID, label, GetUserEmailAccountsFoldersParams{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "CIO: Unable to list folders")
+	}
+	names := make(map[string]bool, len(folders))
+	var regular []string
+	for _, folder := range folders {
+		names[folder.Name] = true
+		if len(folder.SymbolicName) == 0 || strings.EqualFold(folder.Name, "INBOX") {
+			regular = append(regular, folder.Name)
+		}
+	}
+	sort.Strings(regular)
+	return names, regular, nil
+}
+
+// now returns the current time
+func (filer AutoFiler) now() time.Time {
+	if filer.Now != nil {
+		return filer.Now()
+	}
+	return time.Now()
+}
+
+// matchesAddress returns true if the email address matches any of the email addresses or domains (and their subdomains)
+func matchesAddress(patterns []string, email string) bool {
+	if len(email) == 0 {
+		return false
+	}
+	domain := domainOf(email)
+	for _, pattern := range patterns {
+		if strings.Contains(strings.TrimPrefix(pattern, "@"), "@") {
+			if strings.EqualFold(pattern, email) {
+				return true
+			}
+		} else if pattern = strings.ToLower(strings.Trim(pattern, "@.")); domain == pattern || strings.HasSuffix(domain, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package ciolite
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMessageRuleMatches tests matching each condition of a MessageRule
+func TestMessageRuleMatches(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(100000, 0)
+	message := FilingMessage{
+		Subject:     "Your Receipt #123",
+		From:        newSenderIdentity("Billing@Shop.Example.com", "Shop"),
+		Recipients:  []string{"me@home.org", "family@home.org"},
+		List:        MailingList{ID: "deals.example.com"},
+		Attachments: 1,
+		Date:        now.Add(-48 * time.Hour),
+	}
+
+	tests := []struct {
+		rule     MessageRule
+		expected bool
+	}{
+		{MessageRule{}, false},
+		{MessageRule{From: []string{"billing@shop.example.com"}}, true},
+		{MessageRule{From: []string{"example.com"}}, true},
+		{MessageRule{From: []string{"@shop.example.com"}}, true},
+		{MessageRule{From: []string{"ample.com", "other@shop.example.com"}}, false},
+		{MessageRule{Recipients: []string{"FAMILY@home.org"}}, true},
+		{MessageRule{Recipients: []string{"work.org"}}, false},
+		{MessageRule{SubjectContains: []string{"invoice", "receipt"}}, true},
+		{MessageRule{SubjectContains: []string{"invoice"}}, false},
+		{MessageRule{ListIDs: []string{"Deals.Example.com"}}, true},
+		{MessageRule{MailingLists: true}, true},
+		{MessageRule{HasAttachments: Flag(true)}, true},
+		{MessageRule{HasAttachments: Flag(false)}, false},
+		{MessageRule{OlderThan: 24 * time.Hour}, true},
+		{MessageRule{OlderThan: 72 * time.Hour}, false},
+		{MessageRule{Match: func(message FilingMessage) bool { return message.From.Name == "Shop" }}, true},
+		{MessageRule{From: []string{"example.com"}, SubjectContains: []string{"invoice"}}, false},
+	}
+
+	for i, test := range tests {
+		if matched := test.rule.Matches(message, now); matched != test.expected {
+			t.Error("Rule ", i, "; Expected: ", test.expected, "; Got: ", matched)
+		}
+	}
+}
+
+// TestSimulatedAutoFiler tests AutoFiler Scan (with and without DryRun) and HandleWebhook with a simulated server
+func TestSimulatedAutoFiler(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	var lock sync.Mutex
+	var requests []string
+	record := func(r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}
+	writeString := func(w http.ResponseWriter, s string) {
+		_, err := io.WriteString(w, s)
+		Must(err)
+	}
+
+	newsletter := `{"email_message_id": "1", "subject": "Weekly deals", "received_at": 1000,
+		"addresses": {"from": [{"email": "deals@example.com"}]}, "list_headers": ["List-Id: <deals.example.com>"]}`
+	receipt := `{"email_message_id": "2", "subject": "Your receipt", "received_at": 1000, "flags": {"read": true},
+		"addresses": {"from": [{"email": "billing@shop.com"}]}, "attachments": [{"size": 10}]}`
+	other := `{"email_message_id": "3", "subject": "Hello", "addresses": {"from": [{"email": "friend@other.org"}]}}`
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders", func(w http.ResponseWriter, r *http.Request) {
+		writeString(w, `[{"name": "INBOX", "symbolic_name": "\\Inbox"}, {"name": "Lists"}, {"name": "Sent", "symbolic_name": "\\Sent"}]`)
+	})
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include_flags") != "1" {
+			t.Error("Expected include_flags; Got: ", r.URL.RawQuery)
+		}
+		writeString(w, "["+newsletter+","+receipt+","+other+"]")
+	})
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/Lists/messages", func(w http.ResponseWriter, r *http.Request) {
+		writeString(w, `[]`)
+	})
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/1":
+			writeString(w, newsletter)
+		case r.Method == "POST" && r.URL.Path == "/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/1/read":
+			writeString(w, `{"success": true}`)
+		case r.Method == "PUT":
+			writeString(w, `{"success": true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			writeString(w, `{"type": "error", "value": "not found"}`)
+		}
+	})
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/Receipts", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusNotFound)
+			writeString(w, `{"type": "error", "value": "folder not found"}`)
+			return
+		}
+		writeString(w, `{"success": true}`)
+	})
+
+	filer := AutoFiler{
+		CioLite: cioLite,
+		Rules: []MessageRule{
+			{Name: "lists", MailingLists: true, MoveTo: "Lists", MarkRead: true},
+			{Name: "receipts", SubjectContains: []string{"receipt", "invoice"}, HasAttachments: Flag(true), MoveTo: "Receipts", MarkRead: true},
+			{Name: "unused", From: []string{"example.com"}, MoveTo: "Unused"},
+		},
+		DryRun: true,
+	}
+	ctx := context.Background()
+
+	expectedSteps := []AutoFilingStep{
+		{Action: AutoFilingMarkRead, Rule: "lists", UserID: "fakeUserID", Label: "fakeLabel", Folder: "INBOX", MessageID: "1", Subject: "Weekly deals"},
+		{Action: AutoFilingMove, Rule: "lists", UserID: "fakeUserID", Label: "fakeLabel", Folder: "INBOX", MessageID: "1", Subject: "Weekly deals", Target: "Lists"},
+		{Action: AutoFilingCreateFolder, Rule: "receipts", UserID: "fakeUserID", Label: "fakeLabel", Target: "Receipts"},
+		{Action: AutoFilingMove, Rule: "receipts", UserID: "fakeUserID", Label: "fakeLabel", Folder: "INBOX", MessageID: "2", Subject: "Your receipt", Target: "Receipts"},
+	}
+
+	// Dry run plans the steps without applying them (the Sent folder is not scanned, it has no handler)
+	plan, err := filer.Scan(ctx, "fakeUserID", "fakeLabel", nil)
+	if err != nil || !plan.DryRun || plan.Checked != 3 || plan.Matched != 2 || plan.Applied() != 0 || !reflect.DeepEqual(plan.Steps, expectedSteps) || len(requests) != 0 {
+		t.Error("Expected dry run plan: ", expectedSteps, "; Got: ", plan, "; With Requests: ", requests, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+
+	// Applying the plan
+	filer.DryRun = false
+	plan, err = filer.Scan(ctx, "fakeUserID", "fakeLabel", []string{"INBOX"})
+	if err != nil || plan.Applied() != 4 || len(plan.Failed()) != 0 {
+		t.Error("Expected 4 steps applied; Got: ", plan, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+	expectedRequests := []string{
+		"POST /users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/1/read",
+		"PUT /users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/1",
+		"GET /users/fakeUserID/email_accounts/fakeLabel/folders/Receipts",
+		"POST /users/fakeUserID/email_accounts/fakeLabel/folders/Receipts",
+		"PUT /users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/2",
+	}
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Error("Expected requests: ", expectedRequests, "; Got: ", requests)
+	}
+
+	// Webhooks are filed after getting the message for its list headers (if any rule matches mailing lists)
+	requests = nil
+	var callback WebhookCallback
+	Must(json.Unmarshal([]byte(`{"account_id": "fakeUserID", "message_data": {"email_message_id": "1", "subject": "Weekly deals",
+		"addresses": {"from": {"email": "deals@example.com"}}, "email_accounts": [{"label": "fakeLabel", "folder": "INBOX"}]}}`), &callback))
+	plan, err = filer.HandleWebhook(ctx, callback)
+	if err != nil || plan.Checked != 1 || plan.Applied() != 2 || requests[0] != "GET /users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/1" {
+		t.Error("Expected webhook message filed; Got: ", plan, "; With Requests: ", requests, "; With Error: ", err)
+	}
+
+	// Moves are skipped if marking the message read fails
+	filer.Rules = filer.Rules[1:]
+	Must(json.Unmarshal([]byte(`{"account_id": "fakeUserID", "message_data": {"email_message_id": "4", "subject": "Invoice",
+		"files": [{"file_name": "invoice.pdf"}], "email_accounts": [{"label": "fakeLabel", "folder": "INBOX"}]}}`), &callback))
+	plan, err = filer.HandleWebhook(ctx, callback)
+	if failed := plan.Failed(); err == nil || len(failed) != 2 || failed[0].Action != AutoFilingMarkRead || failed[1].Action != AutoFilingMove {
+		t.Error("Expected mark read failure to skip the move; Got: ", plan, "; With Error: ", err)
+	}
+
+	// Rules require conditions and actions
+	filer.Rules = []MessageRule{{Name: "everything", MoveTo: "Archive"}}
+	if _, err = filer.HandleWebhook(ctx, callback); err == nil {
+		t.Error("Expected rule validation error")
+	}
+}
@@ -2,6 +2,7 @@
 package ciolite
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"time"
@@ -33,6 +34,21 @@ func NewCioLiteWithLogger(key string, secret string, logger cioutil.Logger) CioL
 	return CioLite{Cio: cioutil.NewCio(key, secret, logger, DefaultHost, DefaultRequestTimeout)}
 }
 
+// WithContext returns a copy of the CioLite that makes all requests with the given context,
+// which is used for cancellation and as the parent of any tracing spans.
+func (cioLite CioLite) WithContext(ctx context.Context) CioLite {
+	cioLite.Cio = cioLite.Cio.WithContext(ctx)
+	return cioLite
+}
+
+// NewCioLiteWithLeveledLogger returns a CIO Lite struct (with a leveled, structured logger,
+// such as *slog.Logger, cioutil.LogrusLogger, or cioutil.StdLogger) for accessing the CIO Lite API.
+func NewCioLiteWithLeveledLogger(key string, secret string, logger cioutil.LeveledLogger) CioLite {
+	cioLite := NewCioLite(key, secret)
+	cioLite.Log = logger
+	return cioLite
+}
+
 // NewTestCioLiteServer is a convenience function that returns a CioLite object
 // and a *httptest.Server (which must be closed when done being used).
 // The CioLite instance will hit the test server for all requests.
@@ -2,9 +2,12 @@ package ciolite
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/contextio/contextio-go/cioutil"
@@ -64,3 +67,139 @@ func Must(err error) {
 		panic(err)
 	}
 }
+
+// TestRedactionOfAllParamsAndResponses tests that every sensitive field (passwords, secrets, tokens, signatures)
+// of every params and response type is redacted by the default cioutil.Redactor,
+// both from encoded form values / query strings, and from json payloads.
+func TestRedactionOfAllParamsAndResponses(t *testing.T) {
+	t.Parallel()
+
+	params := []interface{}{
+		&GetDiscoveryParams{},
+		&CreateConnectTokenParams{},
+		&CreateOAuthProviderParams{},
+		&GetUsersParams{},
+		&CreateUserParams{},
+		&CreateEmailAccountParams{},
+		&ModifyUserParams{},
+		&GetUserEmailAccountsParams{},
+		&ModifyUserEmailAccountParams{},
+		&GetUserEmailAccountsFoldersParams{},
+		&ModifyUserEmailAccountFolderParams{},
+		&EmailAccountFolderDelimiterParam{},
+		&GetUserEmailAccountsFolderMessageParams{},
+		&MoveUserEmailAccountFolderMessageParams{},
+		&GetUserEmailAccountsFolderMessageBodyParams{},
+		&SetUserEmailAccountsFolderMessageFlagsParams{},
+		&GetUserEmailAccountsFolderMessageHeadersParams{},
+		&CreateUserWebhookParams{},
+		&ModifyUserWebhookParams{},
+	}
+
+	responses := []interface{}{
+		&GetConnectTokenResponse{},
+		&CreateConnectTokenResponse{},
+		&DeleteConnectTokenResponse{},
+		&GetDiscoveryResponse{},
+		&GetOAuthProvidersResponse{},
+		&CreateOAuthProviderResponse{},
+		&DeleteOAuthProviderResponse{},
+		&GetUsersResponse{},
+		&CreateUserResponse{},
+		&ModifyUserResponse{},
+		&DeleteUserResponse{},
+		&GetUsersEmailAccountsResponse{},
+		&CreateEmailAccountResponse{},
+		&ModifyEmailAccountResponse{},
+		&DeleteEmailAccountResponse{},
+		&StatusCallback{},
+		&GetUsersEmailAccountFoldersResponse{},
+		&CreateEmailAccountFolderResponse{},
+		&ModifyEmailAccountFolderResponse{},
+		&DeleteEmailAccountFolderResponse{},
+		&GetUsersEmailAccountFolderMessagesResponse{},
+		&MoveUserEmailAccountFolderMessageResponse{},
+		&GetUserEmailAccountsFolderMessageAttachmentsResponse{},
+		&GetUserEmailAccountsFolderMessageBodyResponse{},
+		&GetUserEmailAccountsFolderMessageFlagsResponse{},
+		&SetUserEmailAccountsFolderMessageFlagsResponse{},
+		&GetUserEmailAccountsFolderMessageHeadersResponse{},
+		&UserEmailAccountsFolderMessageReadResponse{},
+		&GetUsersWebhooksResponse{},
+		&CreateUserWebhookResponse{},
+		&ModifyWebhookResponse{},
+		&DeleteWebhookResponse{},
+		&WebhookCallback{},
+	}
+
+	var redactor cioutil.Redactor
+
+	for _, param := range params {
+		sentinels := fillSentinels(reflect.ValueOf(param).Elem())
+		values, err := cioutil.FormValues(param)
+		if err != nil {
+			t.Error("Unable to encode ", reflect.TypeOf(param), ": ", err)
+			continue
+		}
+		checkSentinels(t, reflect.TypeOf(param), redactor.Values(values).Encode(), sentinels)
+		checkSentinels(t, reflect.TypeOf(param), redactor.URL("https://cio/path?"+values.Encode()), sentinels)
+	}
+
+	for _, response := range responses {
+		sentinels := fillSentinels(reflect.ValueOf(response).Elem())
+		payload, err := json.Marshal(response)
+		if err != nil {
+			t.Error("Unable to marshal ", reflect.TypeOf(response), ": ", err)
+			continue
+		}
+		checkSentinels(t, reflect.TypeOf(response), redactor.Payload(string(payload)), sentinels)
+		checkSentinels(t, reflect.TypeOf(response), redactor.Payload(string(payload[:len(payload)-1])), sentinels)
+	}
+}
+
+// fillSentinels sets every string field (recursively) to a sentinel value based on its json name,
+// returning a map of sentinel value to json name
+func fillSentinels(v reflect.Value) map[string]string {
+	sentinels := make(map[string]string)
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return fillSentinels(v.Elem())
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		return fillSentinels(v.Index(0))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if len(field.PkgPath) > 0 {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if v.Field(i).Kind() == reflect.String {
+				sentinel := "sentinel-" + name + "-value"
+				v.Field(i).SetString(sentinel)
+				sentinels[sentinel] = name
+				continue
+			}
+			for sentinel, nestedName := range fillSentinels(v.Field(i)) {
+				sentinels[sentinel] = nestedName
+			}
+		}
+	}
+	return sentinels
+}
+
+// checkSentinels checks that sensitive sentinels are absent from the redacted output, and that others remain
+func checkSentinels(t *testing.T, typ reflect.Type, redacted string, sentinels map[string]string) {
+	for sentinel, name := range sentinels {
+		sensitive := name == "provider_consumer_key" || name == "password" ||
+			strings.Contains(name, "secret") || strings.Contains(name, "token") || strings.Contains(name, "signature")
+		if sensitive && strings.Contains(redacted, sentinel) {
+			t.Error("Expected ", typ, " field ", name, " to be redacted; Got: ", redacted)
+		} else if !sensitive && !strings.Contains(redacted, sentinel) {
+			t.Error("Expected ", typ, " field ", name, " to remain; Got: ", redacted)
+		}
+	}
+}
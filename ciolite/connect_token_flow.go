@@ -0,0 +1,341 @@
+package ciolite
+
+// Onboarding flow that supports: https://context.io/docs/lite/connect_tokens
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ConnectTokenStateParam is the query string parameter added to the CallbackURL that holds the CSRF state
+	ConnectTokenStateParam = "state"
+
+	// ConnectTokenCallbackParam is the query string parameter CIO adds to the CallbackURL that holds the connect token
+	ConnectTokenCallbackParam = "contextio_token"
+)
+
+var (
+	// ErrConnectTokenStateMissing is returned to the failure hook when the session has no flow in progress
+	ErrConnectTokenStateMissing = errors.New("CIO: No connect token flow in progress for this session")
+
+	// ErrConnectTokenStateMismatch is returned to the failure hook when the callback state or token
+	// does not match the state or token bound to the session
+	ErrConnectTokenStateMismatch = errors.New("CIO: Connect token callback does not match this session")
+)
+
+// ConnectTokenState is the state of an in progress connect token flow, bound to the browser's session
+type ConnectTokenState struct {
+	State string `json:"state"`
+	Token string `json:"token"`
+	Email string `json:"email,omitempty"`
+}
+
+// ConnectTokenSession stores the ConnectTokenState of an in progress flow in the browser's session,
+// binding the CIO callback to the session that started the flow.
+type ConnectTokenSession interface {
+	SaveConnectTokenState(w http.ResponseWriter, r *http.Request, state ConnectTokenState) error
+	LoadConnectTokenState(r *http.Request) (ConnectTokenState, error)
+	ClearConnectTokenState(w http.ResponseWriter, r *http.Request) error
+}
+
+// ConnectTokenFlowResult is given to the success hook once the connect token has been used,
+// and CIO has access to the email account.
+type ConnectTokenFlowResult struct {
+	ConnectToken GetConnectTokenResponse
+	User         GetConnectTokenUserResponse
+	EmailAccount GetUsersEmailAccountsResponse
+}
+
+// ConnectTokenFlow runs the connect token onboarding flow end-to-end:
+// StartHandler creates a connect token and redirects the browser to CIO,
+// and CallbackHandler receives the browser back from CIO, confirms the CSRF state,
+// gets and checks the connect token, and invokes OnSuccess or OnFailure.
+type ConnectTokenFlow struct {
+	CioLite CioLite
+
+	// Required: the absolute url the CallbackHandler is served from, and the session store
+	CallbackURL string
+	Session     ConnectTokenSession
+
+	// Optional: the status callback url to give CIO
+	StatusCallbackURL string
+
+	// Optional: returns the Email, FirstName, and LastName to create the connect token with.
+	// Defaults to the "email", "first_name", and "last_name" request form values.
+	Params func(r *http.Request) (CreateConnectTokenParams, error)
+
+	// Optional: hooks invoked with the result of the flow.
+	// Default to responding with 200 OK, or with the error and a 400 or 502 status code.
+	OnSuccess func(w http.ResponseWriter, r *http.Request, result ConnectTokenFlowResult)
+	OnFailure func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// StartHandler returns the http.Handler that starts the flow
+func (flow ConnectTokenFlow) StartHandler() http.Handler {
+	return http.HandlerFunc(flow.start)
+}
+
+// CallbackHandler returns the http.Handler that CIO redirects the browser back to
+func (flow ConnectTokenFlow) CallbackHandler() http.Handler {
+	return http.HandlerFunc(flow.callback)
+}
+
+// start creates the connect token, binds it to the session, and redirects the browser to CIO
+func (flow ConnectTokenFlow) start(w http.ResponseWriter, r *http.Request) {
+
+	params, err := flow.params(r)
+	if err != nil {
+		flow.failure(w, r, errors.Wrap(err, "CIO: Unable to get connect token params"))
+		return
+	}
+
+	state, err := newConnectTokenState()
+	if err != nil {
+		flow.failure(w, r, err)
+		return
+	}
+
+	params.CallbackURL, err = addQueryValue(flow.CallbackURL, ConnectTokenStateParam, state)
+	if err != nil {
+		flow.failure(w, r, err)
+		return
+	}
+	params.StatusCallbackURL = flow.StatusCallbackURL
+
+	connectToken, err := flow.CioLite.WithContext(r.Context()).CreateConnectToken(params)
+	if err != nil {
+		flow.failure(w, r, err)
+		return
+	}
+	if len(connectToken.Token) == 0 || len(connectToken.BrowserRedirectURL) == 0 {
+		flow.failure(w, r, errors.Errorf("CIO: Connect token response missing token or browser redirect url: %v", connectToken))
+		return
+	}
+
+	err = flow.Session.SaveConnectTokenState(w, r, ConnectTokenState{State: state, Token: connectToken.Token, Email: params.Email})
+	if err != nil {
+		flow.failure(w, r, errors.Wrap(err, "CIO: Unable to save connect token state"))
+		return
+	}
+
+	http.Redirect(w, r, connectToken.BrowserRedirectURL, http.StatusFound)
+}
+
+// callback confirms the state bound to the session, then gets and checks the connect token
+func (flow ConnectTokenFlow) callback(w http.ResponseWriter, r *http.Request) {
+
+	state, err := flow.Session.LoadConnectTokenState(r)
+	if err != nil || len(state.State) == 0 || len(state.Token) == 0 {
+		flow.failure(w, r, ErrConnectTokenStateMissing)
+		return
+	}
+
+	// The state is single use, regardless of the outcome
+	if err = flow.Session.ClearConnectTokenState(w, r); err != nil {
+		flow.failure(w, r, errors.Wrap(err, "CIO: Unable to clear connect token state"))
+		return
+	}
+
+	query := r.URL.Query()
+	if !constantTimeEqual(query.Get(ConnectTokenStateParam), state.State) {
+		flow.failure(w, r, ErrConnectTokenStateMismatch)
+		return
+	}
+	if token := query.Get(ConnectTokenCallbackParam); len(token) > 0 && !constantTimeEqual(token, state.Token) {
+		flow.failure(w, r, ErrConnectTokenStateMismatch)
+		return
+	}
+
+	cioLite := flow.CioLite.WithContext(r.Context())
+
+	connectToken, err := cioLite.GetConnectToken(state.Token)
+	if err != nil {
+		flow.failure(w, r, err)
+		return
+	}
+
+	// Without an email to start with, accept whichever email the user authorized
+	email := state.Email
+	if len(email) == 0 {
+		email = connectToken.Email
+	}
+
+	if err = cioLite.CheckConnectToken(connectToken, email); err != nil {
+		flow.failure(w, r, err)
+		return
+	}
+
+	emailAccount, err := connectToken.User.EmailAccountMatching(email)
+	if err != nil {
+		flow.failure(w, r, err)
+		return
+	}
+
+	result := ConnectTokenFlowResult{ConnectToken: connectToken, User: connectToken.User, EmailAccount: emailAccount}
+	if flow.OnSuccess != nil {
+		flow.OnSuccess(w, r, result)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// params returns the CreateConnectTokenParams for the start request
+func (flow ConnectTokenFlow) params(r *http.Request) (CreateConnectTokenParams, error) {
+	if flow.Params != nil {
+		return flow.Params(r)
+	}
+	return CreateConnectTokenParams{
+		Email:     r.FormValue("email"),
+		FirstName: r.FormValue("first_name"),
+		LastName:  r.FormValue("last_name"),
+	}, nil
+}
+
+// failure invokes OnFailure, or responds with the error
+func (flow ConnectTokenFlow) failure(w http.ResponseWriter, r *http.Request, err error) {
+	if flow.OnFailure != nil {
+		flow.OnFailure(w, r, err)
+		return
+	}
+	status := http.StatusBadGateway
+	if _, ok := AsConnectTokenError(err); ok || err == ErrConnectTokenStateMissing || err == ErrConnectTokenStateMismatch {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// newConnectTokenState returns a new random CSRF state
+func newConnectTokenState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "CIO: Unable to generate connect token state")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// addQueryValue returns the url with the key and value added to its query string
+func addQueryValue(rawURL string, key string, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.Wrap(err, "CIO: Invalid connect token callback url")
+	}
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// constantTimeEqual compares the strings in constant time, and is false if either is empty
+func constantTimeEqual(a string, b string) bool {
+	return len(a) > 0 && len(b) > 0 && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// CookieConnectTokenSession is a ConnectTokenSession that stores the ConnectTokenState
+// in an HttpOnly cookie, signed with Key (which is required) to prevent tampering.
+type CookieConnectTokenSession struct {
+	Key []byte
+
+	// Optional: default to "cio_connect_token", "/", and 1 hour
+	Name   string
+	Path   string
+	MaxAge time.Duration
+
+	Secure bool
+}
+
+// SaveConnectTokenState sets the signed cookie holding the ConnectTokenState
+func (session CookieConnectTokenSession) SaveConnectTokenState(w http.ResponseWriter, r *http.Request, state ConnectTokenState) error {
+	if len(session.Key) == 0 {
+		return errors.New("CIO: CookieConnectTokenSession requires a Key")
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "CIO: Unable to encode connect token state")
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(w, session.cookie(encoded+"."+session.sign(encoded), int(session.maxAge().Seconds())))
+	return nil
+}
+
+// LoadConnectTokenState returns the ConnectTokenState from the signed cookie
+func (session CookieConnectTokenSession) LoadConnectTokenState(r *http.Request) (ConnectTokenState, error) {
+	var state ConnectTokenState
+	if len(session.Key) == 0 {
+		return state, errors.New("CIO: CookieConnectTokenSession requires a Key")
+	}
+	cookie, err := r.Cookie(session.name())
+	if err != nil {
+		return state, errors.Wrap(err, "CIO: Missing connect token cookie")
+	}
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(session.sign(parts[0]))) {
+		return state, errors.New("CIO: Invalid connect token cookie signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return state, errors.Wrap(err, "CIO: Invalid connect token cookie encoding")
+	}
+	err = json.Unmarshal(payload, &state)
+	return state, errors.Wrap(err, "CIO: Invalid connect token cookie")
+}
+
+// ClearConnectTokenState expires the cookie
+func (session CookieConnectTokenSession) ClearConnectTokenState(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, session.cookie("", -1))
+	return nil
+}
+
+// cookie returns the cookie with the given value and max age
+func (session CookieConnectTokenSession) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     session.name(),
+		Value:    value,
+		Path:     session.path(),
+		MaxAge:   maxAge,
+		Secure:   session.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// sign returns the HMAC-SHA256 signature of the value
+func (session CookieConnectTokenSession) sign(value string) string {
+	mac := hmac.New(sha256.New, session.Key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// name returns the cookie name, or the default
+func (session CookieConnectTokenSession) name() string {
+	if len(session.Name) == 0 {
+		return "cio_connect_token"
+	}
+	return session.Name
+}
+
+// path returns the cookie path, or the default
+func (session CookieConnectTokenSession) path() string {
+	if len(session.Path) == 0 {
+		return "/"
+	}
+	return session.Path
+}
+
+// maxAge returns the cookie max age, or the default
+func (session CookieConnectTokenSession) maxAge() time.Duration {
+	if session.MaxAge <= 0 {
+		return time.Hour
+	}
+	return session.MaxAge
+}
@@ -0,0 +1,109 @@
+package ciolite
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestSimulatedConnectTokenFlow tests the start and callback handlers of ConnectTokenFlow with a simulated server
+func TestSimulatedConnectTokenFlow(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	var createdCallbackURL string
+	mux.HandleFunc("/connect_tokens", func(w http.ResponseWriter, r *http.Request) {
+		Must(r.ParseForm())
+		createdCallbackURL = r.PostForm.Get("callback_url")
+		_, err := io.WriteString(w, `{"success":true,"token":"tok123","browser_redirect_url":"https://connect.context.io/tok123"}`)
+		Must(err)
+	})
+	mux.HandleFunc("/connect_tokens/tok123", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `{"token":"tok123","email":"test@gmail.com","used":1462217259,"expires":false,
+			"user":{"id":"user1","email_accounts":[{"status":"OK","label":"test::gmail","username":"test@gmail.com"}]}}`)
+		Must(err)
+	})
+
+	var result ConnectTokenFlowResult
+	var failure error
+	flow := ConnectTokenFlow{
+		CioLite:     cioLite,
+		CallbackURL: "https://yoursite.com/cio/callback?next=home",
+		Session:     CookieConnectTokenSession{Key: []byte("test key")},
+		OnSuccess: func(w http.ResponseWriter, r *http.Request, flowResult ConnectTokenFlowResult) {
+			result = flowResult
+		},
+		OnFailure: func(w http.ResponseWriter, r *http.Request, err error) {
+			failure = err
+		},
+	}
+
+	// Start
+	startRecorder := httptest.NewRecorder()
+	flow.StartHandler().ServeHTTP(startRecorder, httptest.NewRequest("GET", "/cio/start?email=test%40gmail.com", nil))
+
+	if startRecorder.Code != http.StatusFound || startRecorder.Header().Get("Location") != "https://connect.context.io/tok123" {
+		t.Fatal("Expected redirect to CIO; Got: ", startRecorder.Code, startRecorder.Header(), "; With Failure: ", failure, "; With Log: ", logger.String())
+	}
+
+	callbackURL, err := url.Parse(createdCallbackURL)
+	Must(err)
+	state := callbackURL.Query().Get(ConnectTokenStateParam)
+	if len(state) == 0 || callbackURL.Query().Get("next") != "home" {
+		t.Error("Expected callback url with state; Got: ", createdCallbackURL)
+	}
+
+	cookies := startRecorder.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatal("Expected a single HttpOnly session cookie; Got: ", cookies)
+	}
+
+	callback := func(query string, withCookie bool) *httptest.ResponseRecorder {
+		result, failure = ConnectTokenFlowResult{}, nil
+		r := httptest.NewRequest("GET", "/cio/callback?"+query, nil)
+		if withCookie {
+			r.AddCookie(cookies[0])
+		}
+		w := httptest.NewRecorder()
+		flow.CallbackHandler().ServeHTTP(w, r)
+		return w
+	}
+
+	// Callback without the session, or with a forged state, fails
+	callback("state="+state+"&contextio_token=tok123", false)
+	if failure != ErrConnectTokenStateMissing {
+		t.Error("Expected: ", ErrConnectTokenStateMissing, "; Got: ", failure)
+	}
+
+	callback("state=forged&contextio_token=tok123", true)
+	if failure != ErrConnectTokenStateMismatch {
+		t.Error("Expected: ", ErrConnectTokenStateMismatch, "; Got: ", failure)
+	}
+
+	callback("state="+state+"&contextio_token=other", true)
+	if failure != ErrConnectTokenStateMismatch {
+		t.Error("Expected: ", ErrConnectTokenStateMismatch, "; Got: ", failure)
+	}
+
+	// Callback with the session and state succeeds, and clears the session cookie
+	w := callback("state="+state+"&contextio_token=tok123", true)
+	if failure != nil || result.User.ID != "user1" || result.EmailAccount.Label != "test::gmail" || result.ConnectToken.Token != "tok123" {
+		t.Error("Expected successful flow; Got: ", result, "; With Failure: ", failure, "; With Log: ", logger.String())
+	}
+	if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Error("Expected session cookie to be cleared; Got: ", cleared)
+	}
+
+	// Tampered cookie fails
+	tampered := *cookies[0]
+	tampered.Value = "x" + tampered.Value
+	r := httptest.NewRequest("GET", "/cio/callback?state="+state, nil)
+	r.AddCookie(&tampered)
+	if _, err := flow.Session.LoadConnectTokenState(r); err == nil {
+		t.Error("Expected tampered cookie to fail")
+	}
+}
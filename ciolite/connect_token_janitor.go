@@ -0,0 +1,204 @@
+package ciolite
+
+// Garbage collection that supports: https://context.io/docs/lite/connect_tokens
+// and: https://context.io/docs/lite/users/connect_tokens
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultConnectTokenMaxAge is the default age after which an unused connect token is considered abandoned
+	DefaultConnectTokenMaxAge = 24 * time.Hour
+
+	// ConnectTokenReasonExpired is the reason given for unused connect tokens whose expires time has passed
+	ConnectTokenReasonExpired = "expired"
+
+	// ConnectTokenReasonAbandoned is the reason given for unused connect tokens created more than MaxAge ago
+	ConnectTokenReasonAbandoned = "abandoned"
+)
+
+// ConnectTokenJanitor finds unused connect tokens that have expired or been abandoned,
+// both from GetConnectTokens and from GetUserConnectTokens of each user, and deletes them.
+type ConnectTokenJanitor struct {
+	CioLite CioLite
+
+	// Optional: unused connect tokens created longer ago than MaxAge are abandoned (defaults to DefaultConnectTokenMaxAge)
+	MaxAge time.Duration
+
+	// Optional: only check the connect tokens of these users (defaults to all users, from GetUsers)
+	UserIDs []string
+
+	// Optional: report the connect tokens that would be deleted, without deleting them
+	DryRun bool
+
+	// Optional: the current time (defaults to time.Now)
+	Now func() time.Time
+}
+
+// ConnectTokenJanitorReport is the report of a ConnectTokenJanitor run
+type ConnectTokenJanitorReport struct {
+	DryRun bool
+
+	// Number of connect tokens checked
+	Checked int
+
+	// Connect tokens that were deleted, or would be deleted if DryRun
+	Collected []CollectedConnectToken
+}
+
+// CollectedConnectToken is a connect token that was (or would be, if DryRun) deleted by a ConnectTokenJanitor.
+// UserID is empty for connect tokens not belonging to a user, and Err is any error deleting it.
+type CollectedConnectToken struct {
+	Token   string
+	UserID  string
+	Email   string
+	Reason  string
+	Created time.Time
+	Deleted bool
+	Err     error
+}
+
+// Deleted returns the number of connect tokens that were deleted
+func (report ConnectTokenJanitorReport) Deleted() int {
+	deleted := 0
+	for _, collected := range report.Collected {
+		if collected.Deleted {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Failed returns the connect tokens that could not be deleted
+func (report ConnectTokenJanitorReport) Failed() []CollectedConnectToken {
+	var failed []CollectedConnectToken
+	for _, collected := range report.Collected {
+		if collected.Err != nil {
+			failed = append(failed, collected)
+		}
+	}
+	return failed
+}
+
+// Run lists the connect tokens, and deletes (unless DryRun) those that are unused and expired or abandoned.
+// Errors deleting a connect token are recorded in the report, while errors listing connect tokens
+// or users stop the run and are returned along with the report so far.
+func (janitor ConnectTokenJanitor) Run(ctx context.Context) (ConnectTokenJanitorReport, error) {
+
+	report := ConnectTokenJanitorReport{DryRun: janitor.DryRun}
+	cioLite := janitor.CioLite.WithContext(ctx)
+	seen := make(map[string]bool)
+
+	userIDs := janitor.UserIDs
+	if userIDs == nil {
+		var err error
+		if userIDs, err = janitor.allUserIDs(cioLite); err != nil {
+			return report, err
+		}
+	}
+
+	// User connect tokens
+	for _, userID := range userIDs {
+		connectTokens, err := cioLite.GetUserConnectTokens(userID)
+		if err != nil {
+			return report, errors.Wrapf(err, "CIO: Unable to list connect tokens for user %s", userID)
+		}
+		for _, connectToken := range connectTokens {
+			seen[connectToken.Token] = true
+			janitor.collect(cioLite, &report, userID, connectToken)
+		}
+	}
+
+	// Connect tokens not belonging to the users above
+	connectTokens, err := cioLite.GetConnectTokens()
+	if err != nil {
+		return report, errors.Wrap(err, "CIO: Unable to list connect tokens")
+	}
+	for _, connectToken := range connectTokens {
+		if !seen[connectToken.Token] {
+			janitor.collect(cioLite, &report, "", connectToken)
+		}
+	}
+
+	return report, nil
+}
+
+// Reason returns why the connect token should be collected, or an empty string if it should be kept
+func (janitor ConnectTokenJanitor) Reason(connectToken GetConnectTokenResponse) string {
+	if connectToken.Used != 0 || !connectToken.Expires.Unused() {
+		return ""
+	}
+
+	now := janitor.now()
+	if !connectToken.Expires.Time().After(now) {
+		return ConnectTokenReasonExpired
+	}
+
+	maxAge := janitor.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultConnectTokenMaxAge
+	}
+	if connectToken.Created != 0 && now.Sub(connectToken.CreatedTime()) > maxAge {
+		return ConnectTokenReasonAbandoned
+	}
+
+	return ""
+}
+
+// collect checks the connect token, and deletes it (unless DryRun) if it should be collected
+func (janitor ConnectTokenJanitor) collect(cioLite CioLite, report *ConnectTokenJanitorReport, userID string, connectToken GetConnectTokenResponse) {
+	report.Checked++
+
+	reason := janitor.Reason(connectToken)
+	if len(reason) == 0 {
+		return
+	}
+
+	collected := CollectedConnectToken{
+		Token:   connectToken.Token,
+		UserID:  userID,
+		Email:   connectToken.Email,
+		Reason:  reason,
+		Created: connectToken.CreatedTime(),
+	}
+
+	if !janitor.DryRun {
+		var response DeleteConnectTokenResponse
+		if len(userID) > 0 {
+			response, collected.Err = cioLite.DeleteUserConnectToken(userID, connectToken.Token)
+		} else {
+			response, collected.Err = cioLite.DeleteConnectToken(connectToken.Token)
+		}
+		if collected.Err == nil && !response.Success {
+			collected.Err = errors.Errorf("CIO: Unable to delete connect token %s", connectToken.Token)
+		}
+		collected.Deleted = collected.Err == nil
+	}
+
+	report.Collected = append(report.Collected, collected)
+}
+
+// allUserIDs returns the ids of all users
+func (janitor ConnectTokenJanitor) allUserIDs(cioLite CioLite) ([]string, error) {
+	users, err := cioLite.GetAllUsers(GetUsersParams{})
+	if err != nil {
+		return nil, errors.Wrap(err, "CIO: Unable to list users")
+	}
+	userIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	return userIDs, nil
+}
+
+// now returns the current time
+func (janitor ConnectTokenJanitor) now() time.Time {
+	if janitor.Now != nil {
+		return janitor.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,87 @@
+package ciolite
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSimulatedConnectTokenJanitor tests the dry run and deletion of expired and abandoned connect tokens
+func TestSimulatedConnectTokenJanitor(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	now := time.Unix(1500000000, 0)
+	ts := func(d time.Duration) string { return strconv.FormatInt(now.Add(d).Unix(), 10) }
+
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "100" {
+			t.Error("Expected users to be paged; Got: ", r.URL.RawQuery)
+		}
+		_, err := io.WriteString(w, `[{"id":"user1"}]`)
+		Must(err)
+	})
+	mux.HandleFunc("/users/user1/connect_tokens", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `[
+			{"token":"userexpired","used":0,"created":`+ts(-2*time.Hour)+`,"expires":`+ts(-time.Hour)+`},
+			{"token":"userused","used":`+ts(-time.Hour)+`,"created":`+ts(-72*time.Hour)+`,"expires":false}
+		]`)
+		Must(err)
+	})
+	mux.HandleFunc("/connect_tokens", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `[
+			{"token":"userexpired","used":0,"created":`+ts(-2*time.Hour)+`,"expires":`+ts(-time.Hour)+`},
+			{"token":"abandoned","used":0,"created":`+ts(-49*time.Hour)+`,"expires":`+ts(time.Hour)+`},
+			{"token":"fresh","used":0,"created":`+ts(-time.Hour)+`,"expires":`+ts(time.Hour)+`}
+		]`)
+		Must(err)
+	})
+
+	var mu sync.Mutex
+	var deleted []string
+	deleteHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Error("Expected DELETE; Got: ", r.Method)
+		}
+		mu.Lock()
+		deleted = append(deleted, r.URL.Path)
+		mu.Unlock()
+		_, err := io.WriteString(w, `{"success":true}`)
+		Must(err)
+	}
+	mux.HandleFunc("/users/user1/connect_tokens/userexpired", deleteHandler)
+	mux.HandleFunc("/connect_tokens/abandoned", deleteHandler)
+
+	janitor := ConnectTokenJanitor{CioLite: cioLite, MaxAge: 48 * time.Hour, DryRun: true, Now: func() time.Time { return now }}
+
+	// Dry run
+	report, err := janitor.Run(context.Background())
+	if err != nil || report.Checked != 4 || len(report.Collected) != 2 || report.Deleted() != 0 || len(deleted) != 0 {
+		t.Error("Expected dry run report of 2 out of 4 tokens; Got: ", report, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+
+	expected := []CollectedConnectToken{
+		{Token: "userexpired", UserID: "user1", Reason: ConnectTokenReasonExpired, Created: now.Add(-2 * time.Hour)},
+		{Token: "abandoned", Reason: ConnectTokenReasonAbandoned, Created: now.Add(-49 * time.Hour)},
+	}
+	if !reflect.DeepEqual(report.Collected, expected) {
+		t.Error("Expected: ", expected, "; Got: ", report.Collected)
+	}
+
+	// Actual run
+	janitor.DryRun = false
+	report, err = janitor.Run(context.Background())
+	sort.Strings(deleted)
+	if err != nil || report.Deleted() != 2 || len(report.Failed()) != 0 ||
+		!reflect.DeepEqual(deleted, []string{"/connect_tokens/abandoned", "/users/user1/connect_tokens/userexpired"}) {
+		t.Error("Expected 2 deleted tokens; Got: ", report, deleted, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+}
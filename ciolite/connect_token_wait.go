@@ -0,0 +1,107 @@
+package ciolite
+
+// Polling helper that supports: https://context.io/docs/lite/connect_tokens
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultWaitInitialInterval is the default first interval between polls of WaitForConnectToken
+	DefaultWaitInitialInterval = 2 * time.Second
+
+	// DefaultWaitMaxInterval is the default longest interval between polls of WaitForConnectToken
+	DefaultWaitMaxInterval = 30 * time.Second
+)
+
+// WaitForConnectTokenOptions data struct.
+// Optional: UserID (to poll GetUserConnectToken instead of GetConnectToken),
+// Email (the expected email, defaulting to whichever email the user authorized),
+// InitialInterval and MaxInterval (the interval doubles after each poll, up to MaxInterval).
+type WaitForConnectTokenOptions struct {
+	UserID string
+	Email  string
+
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+// WaitForConnectToken polls the connect token, with backoff, until it has been used and
+// CheckConnectToken passes, returning the used connect token.
+// Returns a ConnectTokenError if the token expires unused, or is used but the email does not match
+// or CIO has no access to the account. Returns the context's error if the context is done first,
+// or the error of any failed request.
+func (cioLite CioLite) WaitForConnectToken(ctx context.Context, token string, options WaitForConnectTokenOptions) (GetConnectTokenResponse, error) {
+
+	interval := options.InitialInterval
+	if interval <= 0 {
+		interval = DefaultWaitInitialInterval
+	}
+	maxInterval := options.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultWaitMaxInterval
+	}
+
+	cioLite = cioLite.WithContext(ctx)
+
+	for {
+		connectToken, err := cioLite.getConnectToken(options.UserID, token)
+		if err != nil {
+			if ctx.Err() != nil {
+				return connectToken, errors.Wrap(ctx.Err(), "CIO: Stopped waiting for connect token")
+			}
+			return connectToken, err
+		}
+
+		// Only check the email and access once used, since the email may not be known before then.
+		// Used alone is enough, as CIO may still return the expires timestamp of a used token.
+		if connectToken.Used != 0 {
+			email := options.Email
+			if len(email) == 0 {
+				email = connectToken.Email
+			}
+
+			err = cioLite.CheckConnectToken(connectToken, email)
+
+			// The user may not have been created yet right after the token is used
+			if ConnectTokenOutcomeOf(err) != ConnectTokenNoUser {
+				return connectToken, err
+			}
+
+		} else if connectToken.Expired() {
+			return connectToken, ConnectTokenError{Outcome: ConnectTokenExpired, Token: token}
+		}
+
+		// Don't sleep past the expiry time
+		sleep := interval
+		if connectToken.Used == 0 && connectToken.Expires.Unused() {
+			if untilExpiry := time.Until(connectToken.Expires.Time()); untilExpiry < sleep {
+				sleep = untilExpiry
+			}
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return connectToken, errors.Wrap(ctx.Err(), "CIO: Stopped waiting for connect token")
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// getConnectToken gets the connect token, for the user if a userID is given
+func (cioLite CioLite) getConnectToken(userID string, token string) (GetConnectTokenResponse, error) {
+	if len(userID) > 0 {
+		return cioLite.GetUserConnectToken(userID, token)
+	}
+	return cioLite.GetConnectToken(token)
+}
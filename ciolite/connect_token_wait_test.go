@@ -0,0 +1,106 @@
+package ciolite
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSimulatedWaitForConnectToken tests WaitForConnectToken polling until used, with a simulated server
+func TestSimulatedWaitForConnectToken(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	var polls int32
+	mux.HandleFunc("/users/user1/connect_tokens/tok123", func(w http.ResponseWriter, r *http.Request) {
+		var response string
+		switch atomic.AddInt32(&polls, 1) {
+		case 1:
+			response = `{"token":"tok123","used":0,"expires":` + expires + `}`
+		case 2:
+			response = `{"token":"tok123","email":"test@gmail.com","used":1462217259,"expires":false}`
+		default:
+			response = `{"token":"tok123","email":"test@gmail.com","used":1462217259,"expires":false,
+				"user":{"id":"user1","email_accounts":[{"status":"OK","label":"test::gmail","username":"test@gmail.com"}]}}`
+		}
+		_, err := io.WriteString(w, response)
+		Must(err)
+	})
+
+	options := WaitForConnectTokenOptions{UserID: "user1", Email: "test@gmail.com", InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+	connectToken, err := cioLite.WaitForConnectToken(context.Background(), "tok123", options)
+
+	if err != nil || connectToken.User.ID != "user1" || atomic.LoadInt32(&polls) != 3 {
+		t.Error("Expected used connect token after 3 polls; Got: ", connectToken, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+
+	// Used with a different email
+	options.Email = "other@gmail.com"
+	_, err = cioLite.WaitForConnectToken(context.Background(), "tok123", options)
+	if ConnectTokenOutcomeOf(err) != ConnectTokenEmailMismatch || err.Error() != "Email does not match Context.io token" {
+		t.Error("Expected email mismatch; Got: ", err)
+	}
+}
+
+// TestSimulatedWaitForConnectTokenOutcomes tests the expired, no access, and cancelled outcomes of WaitForConnectToken
+func TestSimulatedWaitForConnectTokenOutcomes(t *testing.T) {
+	t.Parallel()
+
+	cioLite, _, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	notExpired := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+	mux.HandleFunc("/connect_tokens/expired", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `{"token":"expired","used":0,"expires":`+expired+`}`)
+		Must(err)
+	})
+	mux.HandleFunc("/connect_tokens/noaccess", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `{"token":"noaccess","email":"test@gmail.com","used":1462217259,"expires":false,
+			"user":{"id":"user1","email_accounts":[{"status":"INVALID_CREDENTIALS","label":"test::gmail","username":"test@gmail.com"}]}}`)
+		Must(err)
+	})
+	mux.HandleFunc("/connect_tokens/usedexpires", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `{"token":"usedexpires","email":"test@gmail.com","used":1462217259,"expires":`+notExpired+`,
+			"user":{"id":"user1","email_accounts":[{"status":"OK","label":"test::gmail","username":"test@gmail.com"}]}}`)
+		Must(err)
+	})
+	mux.HandleFunc("/connect_tokens/unused", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `{"token":"unused","used":0,"expires":`+notExpired+`}`)
+		Must(err)
+	})
+
+	options := WaitForConnectTokenOptions{InitialInterval: time.Millisecond}
+
+	_, err := cioLite.WaitForConnectToken(context.Background(), "expired", options)
+	if connectTokenErr, ok := AsConnectTokenError(err); !ok || connectTokenErr.Outcome != ConnectTokenExpired || connectTokenErr.Token != "expired" {
+		t.Error("Expected expired; Got: ", err)
+	}
+
+	_, err = cioLite.WaitForConnectToken(context.Background(), "noaccess", options)
+	if ConnectTokenOutcomeOf(err) != ConnectTokenNoAccess {
+		t.Error("Expected no access; Got: ", err)
+	}
+
+	// Used, but CIO still returns the expires timestamp
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if connectToken, err := cioLite.WaitForConnectToken(ctx, "usedexpires", options); err != nil || connectToken.User.ID != "user1" {
+		t.Error("Expected used connect token; Got: ", connectToken, "; With Error: ", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = cioLite.WaitForConnectToken(ctx, "unused", options)
+	if ctx.Err() == nil || err == nil || ConnectTokenOutcomeOf(err) != -1 {
+		t.Error("Expected context deadline error; Got: ", err)
+	}
+}
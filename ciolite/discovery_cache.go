@@ -0,0 +1,183 @@
+package ciolite
+
+// Caching that supports: https://context.io/docs/lite/discovery
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDiscoveryTTL is the default time a found discovery result is cached for
+	DefaultDiscoveryTTL = 24 * time.Hour
+
+	// DefaultDiscoveryNegativeTTL is the default time a not found discovery result is cached for
+	DefaultDiscoveryNegativeTTL = time.Hour
+)
+
+// DiscoveryCacheEntry is a cached discovery result, and when it expires
+type DiscoveryCacheEntry struct {
+	Response GetDiscoveryResponse
+	Expires  time.Time
+}
+
+// DiscoveryStore interface allows pluggable storage (such as redis or memcached) for a DiscoveryCache.
+// Keys are the source type and email domain. Implementations must be safe for concurrent use,
+// and may drop entries at any time.
+type DiscoveryStore interface {
+	Get(key string) (DiscoveryCacheEntry, bool)
+	Set(key string, entry DiscoveryCacheEntry)
+}
+
+// MemoryDiscoveryStore is an in-memory DiscoveryStore
+type MemoryDiscoveryStore struct {
+	mu      sync.RWMutex
+	entries map[string]DiscoveryCacheEntry
+}
+
+// NewMemoryDiscoveryStore returns an empty in-memory DiscoveryStore
+func NewMemoryDiscoveryStore() *MemoryDiscoveryStore {
+	return &MemoryDiscoveryStore{entries: make(map[string]DiscoveryCacheEntry)}
+}
+
+// Get implements DiscoveryStore
+func (store *MemoryDiscoveryStore) Get(key string) (DiscoveryCacheEntry, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	entry, ok := store.entries[key]
+	return entry, ok
+}
+
+// Set implements DiscoveryStore
+func (store *MemoryDiscoveryStore) Set(key string, entry DiscoveryCacheEntry) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.entries[key] = entry
+}
+
+// discoveryCall is an in flight GetDiscovery request, shared by concurrent lookups of the same domain
+type discoveryCall struct {
+	wg       sync.WaitGroup
+	response GetDiscoveryResponse
+	err      error
+}
+
+// DiscoveryCache caches GetDiscovery results by source type and email domain,
+// caching not found results for the shorter NegativeTTL, and never caching errors.
+// Concurrent lookups of the same domain share a single request.
+type DiscoveryCache struct {
+	CioLite CioLite
+
+	// Optional: defaults to a MemoryDiscoveryStore
+	Store DiscoveryStore
+
+	// Optional: default to DefaultDiscoveryTTL and DefaultDiscoveryNegativeTTL
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	// Optional: the current time (defaults to time.Now)
+	Now func() time.Time
+
+	mu    sync.Mutex
+	calls map[string]*discoveryCall
+}
+
+// GetDiscovery returns the cached discovery result for the email address's domain,
+// personalized for the email address, or calls CioLite.GetDiscovery if not cached.
+func (cache *DiscoveryCache) GetDiscovery(queryValues GetDiscoveryParams) (GetDiscoveryResponse, error) {
+	at := strings.LastIndex(queryValues.Email, "@")
+	if at < 0 || at == len(queryValues.Email)-1 {
+		return cache.CioLite.GetDiscovery(queryValues)
+	}
+	key := strings.ToLower(queryValues.SourceType) + ":" + strings.ToLower(queryValues.Email[at+1:])
+
+	store := cache.store()
+	if entry, ok := store.Get(key); ok && cache.now().Before(entry.Expires) {
+		return personalizeDiscovery(entry.Response, queryValues.Email), nil
+	}
+
+	// Join or start the in flight request for this domain
+	cache.mu.Lock()
+	if call, ok := cache.calls[key]; ok {
+		cache.mu.Unlock()
+		call.wg.Wait()
+		return personalizeDiscovery(call.response, queryValues.Email), call.err
+	}
+	call := &discoveryCall{}
+	call.wg.Add(1)
+	cache.calls[key] = call
+	cache.mu.Unlock()
+
+	// Always release the waiters, even if the request or the Store panics
+	completed := false
+	defer func() {
+		if !completed {
+			call.err = errors.New("CIO: Discovery request for " + key + " did not complete")
+		}
+		call.wg.Done()
+
+		cache.mu.Lock()
+		delete(cache.calls, key)
+		cache.mu.Unlock()
+	}()
+
+	call.response, call.err = cache.CioLite.GetDiscovery(queryValues)
+	if call.err == nil {
+		ttl := cache.TTL
+		if ttl <= 0 {
+			ttl = DefaultDiscoveryTTL
+		}
+		if !call.response.Found {
+			ttl = cache.NegativeTTL
+			if ttl <= 0 {
+				ttl = DefaultDiscoveryNegativeTTL
+			}
+		}
+		store.Set(key, DiscoveryCacheEntry{Response: call.response, Expires: cache.now().Add(ttl)})
+	}
+	completed = true
+
+	return call.response, call.err
+}
+
+// store returns the Store, defaulting it to a MemoryDiscoveryStore, and initializes the in flight calls
+func (cache *DiscoveryCache) store() DiscoveryStore {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.Store == nil {
+		cache.Store = NewMemoryDiscoveryStore()
+	}
+	if cache.calls == nil {
+		cache.calls = make(map[string]*discoveryCall)
+	}
+	return cache.Store
+}
+
+// now returns the current time
+func (cache *DiscoveryCache) now() time.Time {
+	if cache.Now != nil {
+		return cache.Now()
+	}
+	return time.Now()
+}
+
+// personalizeDiscovery returns the discovery result for another email address on the same domain,
+// replacing the email, and the username if it was the email address (or its local part)
+func personalizeDiscovery(response GetDiscoveryResponse, email string) GetDiscoveryResponse {
+	original := response.Email
+	if len(original) == 0 || strings.EqualFold(original, email) {
+		return response
+	}
+	response.Email = email
+
+	switch {
+	case strings.EqualFold(response.IMAP.Username, original):
+		response.IMAP.Username = email
+	case strings.EqualFold(response.IMAP.Username, upToSeparator(original, "@")):
+		response.IMAP.Username = upToSeparator(email, "@")
+	}
+	return response
+}
@@ -0,0 +1,158 @@
+package ciolite
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSimulatedDiscoveryCache tests caching by domain, negative caching, expiry, and personalization
+func TestSimulatedDiscoveryCache(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	var requests int32
+	mux.HandleFunc("/discovery", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		email := r.URL.Query().Get("email")
+		response := `{"email":"` + email + `","found":false}`
+		if email == "first@gmail.com" {
+			response = `{"email":"first@gmail.com","type":"gmail","found":true,"imap":{"server":"imap.gmail.com","username":"first@gmail.com","use_ssl":true,"oauth":true,"port":993}}`
+		}
+		_, err := io.WriteString(w, response)
+		Must(err)
+	})
+
+	now := time.Unix(1500000000, 0)
+	cache := &DiscoveryCache{CioLite: cioLite, TTL: time.Hour, NegativeTTL: time.Minute, Now: func() time.Time { return now }}
+
+	// Found, then cached and personalized for the domain
+	response, err := cache.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: "first@gmail.com"})
+	if err != nil || !response.Found || response.IMAP.Username != "first@gmail.com" {
+		t.Error("Expected found discovery; Got: ", response, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+	response, err = cache.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: "Second@GMAIL.com"})
+	if err != nil || response.Email != "Second@GMAIL.com" || response.IMAP.Username != "Second@GMAIL.com" || response.IMAP.Server != "imap.gmail.com" ||
+		atomic.LoadInt32(&requests) != 1 {
+		t.Error("Expected cached personalized discovery; Got: ", response, "; With Error: ", err)
+	}
+
+	// Not found, cached for the negative ttl
+	for i := 0; i < 2; i++ {
+		response, err = cache.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: "test" + strconv.Itoa(i) + "@nowhere.com"})
+		if err != nil || response.Found || response.Email != "test"+strconv.Itoa(i)+"@nowhere.com" {
+			t.Error("Expected not found discovery; Got: ", response, "; With Error: ", err)
+		}
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Error("Expected not found discovery to be cached; Got requests: ", atomic.LoadInt32(&requests))
+	}
+
+	// Negative ttl expires before the ttl
+	now = now.Add(2 * time.Minute)
+	_, err = cache.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: "test@nowhere.com"})
+	_, err2 := cache.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: "third@gmail.com"})
+	if err != nil || err2 != nil || atomic.LoadInt32(&requests) != 3 {
+		t.Error("Expected only the not found discovery to expire; Got requests: ", atomic.LoadInt32(&requests), err, err2)
+	}
+}
+
+// TestSimulatedDiscoveryCacheSingleflight tests that concurrent lookups of the same domain share a single request
+func TestSimulatedDiscoveryCacheSingleflight(t *testing.T) {
+	t.Parallel()
+
+	cioLite, _, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	var requests int32
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	mux.HandleFunc("/discovery", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+		<-release
+		_, err := io.WriteString(w, `{"email":"user@example.com","type":"generic","found":true,"imap":{"server":"imap.example.com","username":"user","port":993}}`)
+		Must(err)
+	})
+
+	cache := &DiscoveryCache{CioLite: cioLite, Store: NewMemoryDiscoveryStore()}
+
+	var wg, started sync.WaitGroup
+	usernames := make([]string, 10)
+	for i := range usernames {
+		wg.Add(1)
+		started.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			response, err := cache.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: "user" + strconv.Itoa(i) + "@example.com"})
+			if err != nil {
+				t.Error("Unexpected error: ", err)
+			}
+			usernames[i] = response.IMAP.Username
+		}(i)
+	}
+
+	// Hold the request until every lookup has started (those joining after it completes are served from the Store)
+	started.Wait()
+	<-arrived
+	close(release)
+	wg.Wait()
+
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Error("Expected a single request; Got: ", requests)
+	}
+	for i, username := range usernames {
+		// The caller that made the request gets it as is, while the others are personalized
+		if len(username) == 0 || (username != "user" && username != "user"+strconv.Itoa(i)) {
+			t.Error("Expected personalized username; Got: ", username)
+		}
+	}
+}
+
+// panickingDiscoveryStore is a DiscoveryStore whose Set panics
+type panickingDiscoveryStore struct{}
+
+// Get implements DiscoveryStore
+func (panickingDiscoveryStore) Get(key string) (DiscoveryCacheEntry, bool) {
+	return DiscoveryCacheEntry{}, false
+}
+
+// Set implements DiscoveryStore
+func (panickingDiscoveryStore) Set(key string, entry DiscoveryCacheEntry) {
+	panic("store unavailable")
+}
+
+// TestSimulatedDiscoveryCachePanic tests that a panicking Store does not block later lookups of the same domain
+func TestSimulatedDiscoveryCachePanic(t *testing.T) {
+	t.Parallel()
+
+	cioLite, _, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/discovery", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `{"email":"user@example.com","found":false}`)
+		Must(err)
+	})
+
+	cache := &DiscoveryCache{CioLite: cioLite, Store: panickingDiscoveryStore{}}
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("Expected store panic")
+				}
+			}()
+			_, _ = cache.GetDiscovery(GetDiscoveryParams{SourceType: "IMAP", Email: "user@example.com"})
+		}()
+	}
+}
@@ -0,0 +1,332 @@
+package ciolite
+
+// Offline DKIM signature verification of raw messages from: https://context.io/docs/lite/users/email_accounts/folders/messages/raw
+// 	https://tools.ietf.org/html/rfc6376
+// 	https://tools.ietf.org/html/rfc8463 (ed25519-sha256)
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DKIMKeyResolver interface allows pluggable lookup of DKIM public keys (such as from DNS, or a cache).
+// LookupDKIMKey returns the TXT record of the selector for the domain, such as "v=DKIM1; k=rsa; p=MIGfMA0...".
+type DKIMKeyResolver interface {
+	LookupDKIMKey(ctx context.Context, selector string, domain string) (string, error)
+}
+
+// DKIMKeyResolverFunc is a function that implements DKIMKeyResolver
+type DKIMKeyResolverFunc func(ctx context.Context, selector string, domain string) (string, error)
+
+// LookupDKIMKey implements DKIMKeyResolver
+func (f DKIMKeyResolverFunc) LookupDKIMKey(ctx context.Context, selector string, domain string) (string, error) {
+	return f(ctx, selector, domain)
+}
+
+// DNSDKIMKeyResolver is a DKIMKeyResolver that looks up the TXT record of selector._domainkey.domain
+type DNSDKIMKeyResolver struct {
+	// Optional: defaults to net.DefaultResolver
+	Resolver *net.Resolver
+}
+
+// LookupDKIMKey implements DKIMKeyResolver
+func (resolver DNSDKIMKeyResolver) LookupDKIMKey(ctx context.Context, selector string, domain string) (string, error) {
+	dns := resolver.Resolver
+	if dns == nil {
+		dns = net.DefaultResolver
+	}
+	records, err := dns.LookupTXT(ctx, selector+"._domainkey."+domain)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(records, ""), nil
+}
+
+// DKIMVerification is the result of verifying one DKIM-Signature of a raw message.
+// Status is AuthPass, AuthFail (the signature or body hash does not match, or it has expired),
+// AuthPermError (the signature or key is malformed, unsupported, or revoked), or AuthTempError (the key lookup failed).
+type DKIMVerification struct {
+	Domain   string
+	Selector string
+	Status   AuthenticationStatus
+	Err      error
+}
+
+// VerifyDKIM verifies each DKIM-Signature of a raw (RFC 5322) message, in the order they appear,
+// looking up the public keys with the resolver. now is the time to check signature expiration against.
+func VerifyDKIM(ctx context.Context, raw string, resolver DKIMKeyResolver, now time.Time) []DKIMVerification {
+	fields, body := splitRawMessage(raw)
+
+	var verifications []DKIMVerification
+	for i, field := range fields {
+		if !strings.EqualFold(field.name, "DKIM-Signature") {
+			continue
+		}
+		verifications = append(verifications, verifyDKIMSignature(ctx, fields, i, body, resolver, now))
+	}
+	return verifications
+}
+
+// headerField is a header field of a raw message, with the whole field (including folding and the final CRLF) in raw
+type headerField struct {
+	name string
+	raw  string
+}
+
+// value returns the value of the field, after the colon
+func (field headerField) value() string {
+	return field.raw[strings.Index(field.raw, ":")+1:]
+}
+
+// splitRawMessage splits a raw message (with CRLF line endings) into its header fields and body
+func splitRawMessage(raw string) ([]headerField, string) {
+	raw = strings.Replace(strings.Replace(raw, "\r\n", "\n", -1), "\n", "\r\n", -1)
+
+	var fields []headerField
+	for len(raw) > 0 && !strings.HasPrefix(raw, "\r\n") {
+		end := strings.Index(raw, "\r\n")
+		if end < 0 {
+			end = len(raw) - 2
+			raw += "\r\n"
+		}
+		line := raw[:end+2]
+		raw = raw[end+2:]
+
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		if colon := strings.Index(line, ":"); colon > 0 {
+			fields = append(fields, headerField{name: strings.TrimSpace(line[:colon]), raw: line})
+		}
+	}
+	return fields, strings.TrimPrefix(raw, "\r\n")
+}
+
+// parseDKIMTags parses a tag=value list (of a DKIM-Signature or DKIM key record), removing whitespace from values
+func parseDKIMTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		eq := strings.Index(tag, "=")
+		if eq < 0 {
+			continue
+		}
+		tags[strings.TrimSpace(tag[:eq])] = strings.Join(strings.Fields(tag[eq+1:]), "")
+	}
+	return tags
+}
+
+// verifyDKIMSignature verifies the DKIM-Signature at fields[index]
+func verifyDKIMSignature(ctx context.Context, fields []headerField, index int, body string, resolver DKIMKeyResolver, now time.Time) DKIMVerification {
+	tags := parseDKIMTags(fields[index].value())
+	verification := DKIMVerification{Domain: strings.ToLower(tags["d"]), Selector: tags["s"]}
+	permError := func(message string) DKIMVerification {
+		verification.Status, verification.Err = AuthPermError, errors.New("CIO: DKIM "+message)
+		return verification
+	}
+	fail := func(message string) DKIMVerification {
+		verification.Status, verification.Err = AuthFail, errors.New("CIO: DKIM "+message)
+		return verification
+	}
+
+	for _, required := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if len(tags[required]) == 0 {
+			return permError("signature is missing the " + required + "= tag")
+		}
+	}
+	if tags["v"] != "1" {
+		return permError("signature has an unsupported version: " + tags["v"])
+	}
+	if !containsFoldString(strings.Split(strings.Replace(tags["h"], " ", "", -1), ":"), "From") {
+		return permError("signature does not sign the From header")
+	}
+	if expires, err := strconv.ParseInt(tags["x"], 10, 64); err == nil && now.Unix() > expires {
+		return fail("signature has expired")
+	}
+
+	var hashFunc crypto.Hash
+	var newHash func() hash.Hash
+	keyType := "rsa"
+	switch strings.ToLower(tags["a"]) {
+	case "rsa-sha256":
+		hashFunc, newHash = crypto.SHA256, sha256.New
+	case "rsa-sha1":
+		hashFunc, newHash = crypto.SHA1, sha1.New
+	case "ed25519-sha256":
+		hashFunc, newHash, keyType = crypto.SHA256, sha256.New, "ed25519"
+	default:
+		return permError("signature has an unsupported algorithm: " + tags["a"])
+	}
+
+	headerCanonicalization, bodyCanonicalization := "simple", "simple"
+	if c := strings.ToLower(tags["c"]); len(c) > 0 {
+		headerCanonicalization = upToSeparator(c, "/")
+		if len(c) > len(headerCanonicalization) {
+			bodyCanonicalization = c[len(headerCanonicalization)+1:]
+		}
+	}
+	if (headerCanonicalization != "simple" && headerCanonicalization != "relaxed") || (bodyCanonicalization != "simple" && bodyCanonicalization != "relaxed") {
+		return permError("signature has an unsupported canonicalization: " + tags["c"])
+	}
+
+	// Body hash
+	canonicalBody := canonicalizeDKIMBody(body, bodyCanonicalization)
+	if l, ok := tags["l"]; ok {
+		length, err := strconv.Atoi(l)
+		if err != nil || length < 0 {
+			return permError("signature has a malformed l= tag")
+		}
+		if length > len(canonicalBody) {
+			return fail("signature length is longer than the body")
+		}
+		canonicalBody = canonicalBody[:length]
+	}
+	bodyHash := newHash()
+	bodyHash.Write([]byte(canonicalBody))
+	expectedBodyHash, err := base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil {
+		return permError("signature has a malformed bh= tag")
+	}
+	if subtle.ConstantTimeCompare(bodyHash.Sum(nil), expectedBodyHash) != 1 {
+		return fail("body hash does not match")
+	}
+
+	// Header hash, selecting the signed header fields from the bottom up, then the signature itself without b=
+	headerHash := newHash()
+	used := make(map[int]bool)
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fields[i].name, strings.TrimSpace(name)) {
+				used[i] = true
+				headerHash.Write([]byte(canonicalizeDKIMHeader(fields[i].raw, headerCanonicalization)))
+				break
+			}
+		}
+	}
+	signature := canonicalizeDKIMHeader(removeDKIMSignatureValue(fields[index].raw), headerCanonicalization)
+	headerHash.Write([]byte(strings.TrimSuffix(signature, "\r\n")))
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return permError("signature has a malformed b= tag")
+	}
+
+	// Public key
+	record, err := resolver.LookupDKIMKey(ctx, verification.Selector, verification.Domain)
+	if err != nil {
+		verification.Status, verification.Err = AuthTempError, errors.Wrap(err, "CIO: Unable to look up DKIM key")
+		return verification
+	}
+	keyTags := parseDKIMTags(record)
+	recordKeyType := strings.ToLower(keyTags["k"])
+	if len(recordKeyType) == 0 {
+		recordKeyType = "rsa"
+	}
+	if recordKeyType != keyType {
+		return permError("key type does not match the signature algorithm")
+	}
+	if len(keyTags["p"]) == 0 {
+		return permError("key has been revoked")
+	}
+	keyBytes, err := base64.StdEncoding.DecodeString(keyTags["p"])
+	if err != nil {
+		return permError("key is malformed")
+	}
+
+	switch keyType {
+	case "ed25519":
+		if len(keyBytes) != ed25519.PublicKeySize {
+			return permError("key is malformed")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(keyBytes), headerHash.Sum(nil), signatureBytes) {
+			return fail("signature does not match")
+		}
+	default:
+		parsed, err := x509.ParsePKIXPublicKey(keyBytes)
+		if err != nil {
+			if parsed, err = x509.ParsePKCS1PublicKey(keyBytes); err != nil {
+				return permError("key is malformed")
+			}
+		}
+		publicKey, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return permError("key is not an RSA key")
+		}
+		if err := rsa.VerifyPKCS1v15(publicKey, hashFunc, headerHash.Sum(nil), signatureBytes); err != nil {
+			return fail("signature does not match")
+		}
+	}
+
+	verification.Status = AuthPass
+	return verification
+}
+
+// canonicalizeDKIMHeader canonicalizes a raw header field (ending with CRLF) with the simple or relaxed algorithm
+func canonicalizeDKIMHeader(raw string, canonicalization string) string {
+	if canonicalization == "simple" {
+		return raw
+	}
+	colon := strings.Index(raw, ":")
+	value := strings.Replace(raw[colon+1:], "\r\n", "", -1)
+	return strings.ToLower(strings.TrimSpace(raw[:colon])) + ":" + strings.Join(strings.FieldsFunc(value, isDKIMWhitespace), " ") + "\r\n"
+}
+
+// canonicalizeDKIMBody canonicalizes a body (with CRLF line endings) with the simple or relaxed algorithm
+func canonicalizeDKIMBody(body string, canonicalization string) string {
+	if canonicalization == "relaxed" {
+		lines := strings.Split(body, "\r\n")
+		for i, line := range lines {
+			lines[i] = strings.Join(strings.FieldsFunc(line, isDKIMWhitespace), " ")
+			if len(line) > 0 && isDKIMWhitespace(rune(line[0])) && len(lines[i]) > 0 {
+				lines[i] = " " + lines[i]
+			}
+		}
+		body = strings.Join(lines, "\r\n")
+	}
+
+	// Remove trailing empty lines
+	for strings.HasSuffix(body, "\r\n") {
+		body = strings.TrimSuffix(body, "\r\n")
+	}
+	if len(body) > 0 {
+		return body + "\r\n"
+	}
+	if canonicalization == "simple" {
+		return "\r\n"
+	}
+	return ""
+}
+
+// removeDKIMSignatureValue returns the raw DKIM-Signature header field with the value of its b= tag removed
+func removeDKIMSignatureValue(raw string) string {
+	colon := strings.Index(raw, ":")
+	tags := strings.Split(raw[colon+1:], ";")
+	for i, tag := range tags {
+		if eq := strings.Index(tag, "="); eq >= 0 && strings.TrimSpace(tag[:eq]) == "b" {
+			tags[i] = tag[:eq+1]
+			if i == len(tags)-1 {
+				tags[i] += "\r\n"
+			}
+		}
+	}
+	return raw[:colon+1] + strings.Join(tags, ";")
+}
+
+// isDKIMWhitespace returns true for the whitespace characters of DKIM canonicalization
+func isDKIMWhitespace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
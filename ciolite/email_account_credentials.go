@@ -0,0 +1,197 @@
+package ciolite
+
+// Credential refresh workflow that supports: https://context.io/docs/lite/users/email_accounts#id-post
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/contextio/contextio-go/cioutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRefreshVerifyTimeout is the default time to wait for an email account to return to OK status
+	DefaultRefreshVerifyTimeout = 30 * time.Second
+
+	// DefaultRefreshVerifyInterval is the default first interval between checks of the email account status
+	DefaultRefreshVerifyInterval = time.Second
+)
+
+// CredentialRefreshOutcome is the outcome of refreshing the credentials of an email account
+type CredentialRefreshOutcome string
+
+const (
+	// CredentialRefreshOK means the credentials were updated and the email account returned to OK status
+	CredentialRefreshOK CredentialRefreshOutcome = "ok"
+
+	// CredentialRefreshInvalidCredentials means CIO rejected the new credentials (such as a revoked refresh token)
+	CredentialRefreshInvalidCredentials CredentialRefreshOutcome = "invalid_credentials"
+
+	// CredentialRefreshConnectionFailed means CIO was unable to connect to the mail server
+	CredentialRefreshConnectionFailed CredentialRefreshOutcome = "connection_failed"
+
+	// CredentialRefreshIMAPDisabled means IMAP access needs to be enabled for the mailbox
+	CredentialRefreshIMAPDisabled CredentialRefreshOutcome = "imap_disabled"
+
+	// CredentialRefreshNotOK means the update was accepted, but the email account did not return to OK status in time
+	CredentialRefreshNotOK CredentialRefreshOutcome = "not_ok"
+
+	// CredentialRefreshFailed means the update failed for some other reason
+	CredentialRefreshFailed CredentialRefreshOutcome = "failed"
+)
+
+// CredentialRefreshError is the error returned by RefreshEmailAccountCredentials when the email account
+// did not return to OK status, with the Outcome interpreted from the FeedbackCode, ConnectionLog, and Status.
+type CredentialRefreshError struct {
+	Outcome       CredentialRefreshOutcome
+	FeedbackCode  string
+	ConnectionLog string
+	Status        string
+}
+
+// Error returns the outcome along with the feedback code or status
+func (e CredentialRefreshError) Error() string {
+	message := "CIO: Unable to refresh email account credentials: " + string(e.Outcome)
+	if len(e.FeedbackCode) > 0 {
+		message += "; FeedbackCode: " + e.FeedbackCode
+	}
+	if len(e.Status) > 0 {
+		message += "; Status: " + e.Status
+	}
+	return message
+}
+
+// AsCredentialRefreshError returns the CredentialRefreshError if err is (or was caused by) one
+func AsCredentialRefreshError(err error) (CredentialRefreshError, bool) {
+	refreshErr, ok := errors.Cause(err).(CredentialRefreshError)
+	return refreshErr, ok
+}
+
+// RefreshEmailAccountCredentialsParams data struct.
+// Requires either ProviderRefreshToken and ProviderConsumerKey (for OAuth), or Password, but not both.
+// Optional: VerifyTimeout and VerifyInterval (the interval doubles after each check).
+type RefreshEmailAccountCredentialsParams struct {
+	ProviderRefreshToken cioutil.Secret
+	ProviderConsumerKey  string
+	Password             cioutil.Secret
+
+	VerifyTimeout  time.Duration
+	VerifyInterval time.Duration
+}
+
+// RefreshEmailAccountCredentials updates the credentials of the email account with ModifyUserEmailAccount
+// (forcing a status check), then waits for the email account to return to OK status, returning it.
+// Returns a CredentialRefreshError if CIO does not accept the credentials, or the account does not return to OK.
+func (cioLite CioLite) RefreshEmailAccountCredentials(ctx context.Context, userID string, label string, params RefreshEmailAccountCredentialsParams) (GetUsersEmailAccountsResponse, error) {
+
+	oauth := len(params.ProviderRefreshToken) > 0 || len(params.ProviderConsumerKey) > 0
+	if oauth == (len(params.Password) > 0) || (oauth && (len(params.ProviderRefreshToken) == 0 || len(params.ProviderConsumerKey) == 0)) {
+		return GetUsersEmailAccountsResponse{}, errors.New("CIO: Refreshing credentials requires either ProviderRefreshToken and ProviderConsumerKey, or Password")
+	}
+
+	cioLite = cioLite.WithContext(ctx)
+
+	modified, err := cioLite.ModifyUserEmailAccount(userID, label, ModifyUserEmailAccountParams{
+		ProviderRefreshToken: params.ProviderRefreshToken,
+		ProviderConsumerKey:  params.ProviderConsumerKey,
+		Password:             params.Password,
+		ForceStatusCheck:     true,
+	})
+	if err != nil {
+		return GetUsersEmailAccountsResponse{}, err
+	}
+	if !modified.Success {
+		return GetUsersEmailAccountsResponse{}, CredentialRefreshError{
+			Outcome:       InterpretFeedback(modified.FeedbackCode, modified.ConnectionLog),
+			FeedbackCode:  modified.FeedbackCode,
+			ConnectionLog: modified.ConnectionLog,
+		}
+	}
+
+	// Verify the email account returns to OK
+	timeout := params.VerifyTimeout
+	if timeout <= 0 {
+		timeout = DefaultRefreshVerifyTimeout
+	}
+	interval := params.VerifyInterval
+	if interval <= 0 {
+		interval = DefaultRefreshVerifyInterval
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		emailAccount, err := cioLite.GetUserEmailAccount(userID, label)
+		if err != nil {
+			return emailAccount, err
+		}
+		if emailAccount.Status == "OK" {
+			return emailAccount, nil
+		}
+
+		if !time.Now().Add(interval).Before(deadline) {
+			return emailAccount, CredentialRefreshError{
+				Outcome:       interpretStatus(emailAccount.Status),
+				FeedbackCode:  modified.FeedbackCode,
+				ConnectionLog: modified.ConnectionLog,
+				Status:        emailAccount.Status,
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return emailAccount, errors.Wrap(ctx.Err(), "CIO: Stopped verifying email account status")
+		case <-timer.C:
+		}
+		interval *= 2
+	}
+}
+
+// InterpretFeedback returns the CredentialRefreshOutcome best describing the FeedbackCode and ConnectionLog
+// of a ModifyEmailAccountResponse. The feedback codes are not enumerated by CIO, so they are matched loosely.
+func InterpretFeedback(feedbackCode string, connectionLog string) CredentialRefreshOutcome {
+	code := strings.ToUpper(feedbackCode)
+	log := strings.ToUpper(connectionLog)
+
+	switch {
+	case len(code) == 0 && len(log) == 0:
+		return CredentialRefreshFailed
+	case code == "OK":
+		return CredentialRefreshOK
+	case strings.Contains(code, "IMAP") || strings.Contains(log, "IMAP ACCESS IS DISABLED") || strings.Contains(log, "ENABLE IMAP"):
+		return CredentialRefreshIMAPDisabled
+	case containsAny(code, "CREDENTIAL", "AUTH", "TOKEN", "PASSWORD", "LOGIN") ||
+		containsAny(log, "AUTHENTICATIONFAILED", "INVALID CREDENTIALS", "INVALID_GRANT", "AUTHENTICATE FAILED", "LOGIN FAILED"):
+		return CredentialRefreshInvalidCredentials
+	case containsAny(code, "CONNECTION", "TIMEOUT", "HOST", "SSL", "UNREACHABLE") ||
+		containsAny(log, "CONNECTION REFUSED", "TIMED OUT", "UNKNOWN HOST", "NO SUCH HOST"):
+		return CredentialRefreshConnectionFailed
+	}
+	return CredentialRefreshFailed
+}
+
+// interpretStatus returns the CredentialRefreshOutcome best describing an email account status
+func interpretStatus(status string) CredentialRefreshOutcome {
+	switch status {
+	case "OK":
+		return CredentialRefreshOK
+	case "INVALID_CREDENTIALS":
+		return CredentialRefreshInvalidCredentials
+	case "CONNECTION_IMPOSSIBLE":
+		return CredentialRefreshConnectionFailed
+	}
+	return CredentialRefreshNotOK
+}
+
+// containsAny returns true if s contains any of the substrings
+func containsAny(s string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
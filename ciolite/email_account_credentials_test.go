@@ -0,0 +1,116 @@
+package ciolite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSimulatedRefreshEmailAccountCredentials tests updating credentials and verifying the email account returns to OK
+func TestSimulatedRefreshEmailAccountCredentials(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	var mu sync.Mutex
+	var form map[string][]string
+	gets := 0
+
+	mux.HandleFunc("/users/user1/email_accounts/good", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == "POST" {
+			Must(r.ParseForm())
+			form = r.PostForm
+			Must(json.NewEncoder(w).Encode(ModifyEmailAccountResponse{Success: true, FeedbackCode: "OK"}))
+			return
+		}
+		gets++
+		status := "INVALID_CREDENTIALS"
+		if gets > 1 {
+			status = "OK"
+		}
+		Must(json.NewEncoder(w).Encode(GetUsersEmailAccountsResponse{Label: "good", Status: status}))
+	})
+	mux.HandleFunc("/users/user1/email_accounts/revoked", func(w http.ResponseWriter, r *http.Request) {
+		Must(json.NewEncoder(w).Encode(ModifyEmailAccountResponse{
+			FeedbackCode:  "INVALID_CREDENTIALS",
+			ConnectionLog: "* NO [AUTHENTICATIONFAILED] Invalid credentials (Failure)",
+		}))
+	})
+	mux.HandleFunc("/users/user1/email_accounts/stuck", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			Must(json.NewEncoder(w).Encode(ModifyEmailAccountResponse{Success: true}))
+			return
+		}
+		Must(json.NewEncoder(w).Encode(GetUsersEmailAccountsResponse{Label: "stuck", Status: "CONNECTION_IMPOSSIBLE"}))
+	})
+
+	params := RefreshEmailAccountCredentialsParams{
+		ProviderRefreshToken: "refresh",
+		ProviderConsumerKey:  "key",
+		VerifyTimeout:        time.Second,
+		VerifyInterval:       time.Millisecond,
+	}
+
+	// Success after the second check
+	emailAccount, err := cioLite.RefreshEmailAccountCredentials(context.Background(), "user1", "good", params)
+	mu.Lock()
+	if err != nil || emailAccount.Status != "OK" || gets != 2 ||
+		form["provider_refresh_token"][0] != "refresh" || form["provider_consumer_key"][0] != "key" || form["force_status_check"][0] != "1" {
+		t.Error("Expected OK email account; Got: ", emailAccount, gets, form, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+	mu.Unlock()
+
+	// Rejected
+	_, err = cioLite.RefreshEmailAccountCredentials(context.Background(), "user1", "revoked", params)
+	if refreshErr, ok := AsCredentialRefreshError(err); !ok || refreshErr.Outcome != CredentialRefreshInvalidCredentials || refreshErr.FeedbackCode != "INVALID_CREDENTIALS" {
+		t.Error("Expected invalid credentials; Got: ", err)
+	}
+
+	// Never returns to OK
+	params.VerifyTimeout = 10 * time.Millisecond
+	_, err = cioLite.RefreshEmailAccountCredentials(context.Background(), "user1", "stuck", params)
+	if refreshErr, ok := AsCredentialRefreshError(err); !ok || refreshErr.Outcome != CredentialRefreshConnectionFailed || refreshErr.Status != "CONNECTION_IMPOSSIBLE" {
+		t.Error("Expected connection failed; Got: ", err)
+	}
+
+	// Both or neither credentials
+	params.Password = "hunter2"
+	if _, err = cioLite.RefreshEmailAccountCredentials(context.Background(), "user1", "good", params); err == nil {
+		t.Error("Expected error with both oauth and password credentials")
+	}
+	if _, err = cioLite.RefreshEmailAccountCredentials(context.Background(), "user1", "good", RefreshEmailAccountCredentialsParams{}); err == nil {
+		t.Error("Expected error without credentials")
+	}
+}
+
+// TestInterpretFeedback tests interpreting feedback codes and connection logs into outcomes
+func TestInterpretFeedback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		feedbackCode  string
+		connectionLog string
+		expected      CredentialRefreshOutcome
+	}{
+		{"OK", "", CredentialRefreshOK},
+		{"", "", CredentialRefreshFailed},
+		{"INVALID_CREDENTIALS", "", CredentialRefreshInvalidCredentials},
+		{"", "oauth error: invalid_grant", CredentialRefreshInvalidCredentials},
+		{"IMAP_DISABLED", "", CredentialRefreshIMAPDisabled},
+		{"", "Your account is not enabled for IMAP use. Please enable IMAP access", CredentialRefreshIMAPDisabled},
+		{"CONNECTION_TIMEOUT", "", CredentialRefreshConnectionFailed},
+		{"SOMETHING_ELSE", "", CredentialRefreshFailed},
+	}
+
+	for _, test := range tests {
+		if outcome := InterpretFeedback(test.feedbackCode, test.connectionLog); outcome != test.expected {
+			t.Error("Expected: ", test.expected, "; Got: ", outcome, "; For: ", test.feedbackCode, test.connectionLog)
+		}
+	}
+}
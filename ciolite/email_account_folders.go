@@ -0,0 +1,172 @@
+package ciolite
+
+// Folder cleanup built on: https://context.io/docs/lite/users/email_accounts/folders
+// and: https://context.io/docs/lite/users/email_accounts/folders/messages#id-put
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SafeDeleteUserEmailAccountFolderParams data struct.
+// Optional: Delimiter (defaults to the Delimiter of the folder, or "/"), MoveToParent,
+// and PageSize (the Limit of each GetUserEmailAccountsFolderMessages request, defaults to 100).
+type SafeDeleteUserEmailAccountFolderParams struct {
+	Delimiter string
+
+	// MoveToParent moves the messages of the folder and its subfolders into the parent of the folder before deleting them.
+	// Otherwise nothing is deleted if any of the folders contain messages.
+	MoveToParent bool
+
+	PageSize int
+}
+
+// SafeDeleteUserEmailAccountFolder will safely delete a folder and its subfolders (deepest first) without losing messages:
+// either all of the folders must be empty, or with MoveToParent their messages are first moved into the parent folder.
+// Special folders (INBOX, or any with a SymbolicName such as \Sent) are never deleted.
+// This function returns the folders it deleted (none if the folder does not exist), and any errors it received.
+func (cioLite CioLite) SafeDeleteUserEmailAccountFolder(ctx context.Context, userID string, label string, folder string, params SafeDeleteUserEmailAccountFolderParams) ([]string, error) {
+	cioLite = cioLite.WithContext(ctx)
+
+	allFolders, err := cioLite.GetUserEmailAccountsFolders(userID, label, GetUserEmailAccountsFoldersParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	delimiter := params.Delimiter
+	found := false
+	for _, singleFolder := range allFolders {
+		if singleFolder.Name == folder {
+			found = true
+			if len(delimiter) == 0 {
+				delimiter = singleFolder.Delimiter
+			}
+		}
+	}
+	if !found {
+		// It does not exist, so there is nothing to delete
+		return nil, nil
+	}
+	if len(delimiter) == 0 {
+		delimiter = "/"
+	}
+
+	var tree []string
+	for _, singleFolder := range allFolders {
+		if singleFolder.Name != folder && !strings.HasPrefix(singleFolder.Name, folder+delimiter) {
+			continue
+		}
+		if len(singleFolder.SymbolicName) > 0 || strings.EqualFold(singleFolder.Name, "INBOX") {
+			return nil, errors.New("CIO: Refusing to delete special folder " + singleFolder.Name)
+		}
+		tree = append(tree, singleFolder.Name)
+	}
+	sort.SliceStable(tree, func(i, j int) bool {
+		if depthI, depthJ := strings.Count(tree[i], delimiter), strings.Count(tree[j], delimiter); depthI != depthJ {
+			return depthI > depthJ
+		}
+		return tree[i] < tree[j]
+	})
+
+	var parent string
+	if params.MoveToParent {
+		parent = parentFolder(folder, delimiter)
+		if len(parent) == 0 {
+			return nil, errors.New("CIO: Unable to move messages out of top-level folder " + folder + ", it has no parent")
+		}
+	}
+
+	pageSize := params.PageSize
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+
+	// Check every folder is empty before deleting any of them
+	if !params.MoveToParent {
+		for _, name := range tree {
+			page, err := cioLite.GetUserEmailAccountsFolderMessages(userID, label, name, GetUserEmailAccountsFolderMessageParams{Delimiter: params.Delimiter, Limit: 1})
+			if err != nil {
+				return nil, errors.Wrap(err, "CIO: Unable to list messages in folder "+name)
+			}
+			if len(page) > 0 {
+				return nil, errors.New("CIO: Refusing to delete folder " + name + ", it contains messages")
+			}
+		}
+	}
+
+	deleted := make([]string, 0, len(tree))
+	for _, name := range tree {
+		if err := ctx.Err(); err != nil {
+			return deleted, errors.Wrap(err, "CIO: Stopped deleting folders")
+		}
+
+		if params.MoveToParent {
+			if err := cioLite.moveFolderMessages(ctx, userID, label, name, parent, params.Delimiter, pageSize); err != nil {
+				return deleted, err
+			}
+		}
+
+		response, err := cioLite.DeleteUserEmailAccountFolder(userID, label, name, EmailAccountFolderDelimiterParam{Delimiter: params.Delimiter})
+		if err != nil {
+			return deleted, err
+		}
+		if !response.Success {
+			return deleted, errors.New("Unable to delete folder " + name + ". CIO returned 200 but with Success=false")
+		}
+		deleted = append(deleted, name)
+	}
+
+	return deleted, nil
+}
+
+// moveFolderMessages moves every message in folder into newFolder, a page at a time
+func (cioLite CioLite) moveFolderMessages(ctx context.Context, userID string, label string, folder string, newFolder string, delimiter string, pageSize int) error {
+	queryValues := GetUserEmailAccountsFolderMessageParams{Delimiter: delimiter, Limit: pageSize}
+	moveParams := MoveUserEmailAccountFolderMessageParams{NewFolderID: newFolder, Delimiter: delimiter}
+	moved := make(map[string]bool)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "CIO: Stopped moving messages")
+		}
+
+		// Moved messages leave the folder, so always list the first page
+		page, err := cioLite.GetUserEmailAccountsFolderMessages(userID, label, folder, queryValues)
+		if err != nil {
+			return errors.Wrap(err, "CIO: Unable to list messages in folder "+folder)
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		for _, message := range page {
+			id := message.EmailMessageID
+			if len(id) == 0 {
+				id = message.MessageID
+			}
+			if len(id) == 0 || moved[id] {
+				return errors.New("CIO: Unable to move message " + id + " out of folder " + folder)
+			}
+
+			response, err := cioLite.MoveUserEmailAccountFolderMessage(userID, label, folder, id, moveParams)
+			if err != nil {
+				return errors.Wrap(err, "CIO: Unable to move message "+id+" to folder "+newFolder)
+			}
+			if !response.Success {
+				return errors.New("Unable to move message " + id + ". CIO returned 200 but with Success=false")
+			}
+			moved[id] = true
+		}
+	}
+}
+
+// parentFolder returns the parent of a folder, or "" if it is a top-level folder
+func parentFolder(folder string, delimiter string) string {
+	if index := strings.LastIndex(folder, delimiter); index > 0 {
+		return folder[:index]
+	}
+	return ""
+}
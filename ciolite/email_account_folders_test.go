@@ -0,0 +1,145 @@
+package ciolite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/contextio/contextio-go/cioutil"
+)
+
+// TestSimulatedSafeDeleteUserEmailAccountFolder tests SafeDeleteUserEmailAccountFolder and ModifyUserEmailAccountFolder with a simulated server
+func TestSimulatedSafeDeleteUserEmailAccountFolder(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	var lock sync.Mutex
+	folders := map[string][]string{
+		"INBOX":           {"1"},
+		"Auto":            nil,
+		"Auto/News":       {"2"},
+		"Auto/News/Daily": {"3", "4"},
+		"Auto/Shop":       nil,
+		"Auto/Shopping":   {"5"},
+	}
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		Must(json.NewEncoder(w).Encode(v))
+	}
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		var response []GetUsersEmailAccountFoldersResponse
+		for name, messages := range folders {
+			folder := GetUsersEmailAccountFoldersResponse{Name: name, Delimiter: "/", NbMessages: len(messages)}
+			if name == "INBOX" {
+				folder.SymbolicName = `\Inbox`
+			}
+			response = append(response, folder)
+		}
+		writeJSON(w, response)
+	})
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		Must(r.ParseForm())
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/users/fakeUserID/email_accounts/fakeLabel/folders/"), "/")
+		folder, err := url.QueryUnescape(parts[0])
+		Must(err)
+		messages, exists := folders[folder]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]string{"type": "error", "value": "folder not found"})
+			return
+		}
+
+		switch {
+		case r.Method == "GET" && len(parts) == 2:
+			if limit := r.Form.Get("limit"); limit != "1" && limit != "2" {
+				t.Error("Expected limit 1 or 2; Got: ", limit)
+			}
+			if len(messages) > 2 {
+				messages = messages[:2]
+			}
+			response := []GetUsersEmailAccountFolderMessagesResponse{}
+			for _, id := range messages {
+				response = append(response, GetUsersEmailAccountFolderMessagesResponse{EmailMessageID: id})
+			}
+			writeJSON(w, response)
+		case r.Method == "PUT" && len(parts) == 3:
+			newFolder := r.Form.Get("new_folder_id")
+			for i, id := range messages {
+				if id == parts[2] {
+					folders[folder] = append(messages[:i:i], messages[i+1:]...)
+					folders[newFolder] = append(folders[newFolder], id)
+				}
+			}
+			writeJSON(w, MoveUserEmailAccountFolderMessageResponse{Success: true})
+		case r.Method == "PUT" && len(parts) == 1:
+			if r.PostForm.Get("new_name") != "Auto/Shops" || r.PostForm.Get("subscribed") != "1" {
+				t.Error("Expected new_name and subscribed; Got: ", r.PostForm)
+			}
+			folders["Auto/Shops"] = messages
+			delete(folders, folder)
+			writeJSON(w, ModifyEmailAccountFolderResponse{Success: true})
+		case r.Method == "DELETE" && len(parts) == 1:
+			delete(folders, folder)
+			writeJSON(w, DeleteEmailAccountFolderResponse{Success: true})
+		default:
+			t.Error("Unexpected request: ", r.Method, " ", r.URL)
+		}
+	})
+
+	ctx := context.Background()
+
+	// Folders with messages are not deleted without MoveToParent
+	deleted, err := cioLite.SafeDeleteUserEmailAccountFolder(ctx, "fakeUserID", "fakeLabel", "Auto/News", SafeDeleteUserEmailAccountFolderParams{})
+	if err == nil || len(deleted) != 0 || len(folders["Auto/News/Daily"]) != 2 {
+		t.Error("Expected no folders deleted; Got: ", deleted, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+
+	// The folder and its subfolder are deleted deepest first, after moving their messages to the parent
+	deleted, err = cioLite.SafeDeleteUserEmailAccountFolder(ctx, "fakeUserID", "fakeLabel", "Auto/News", SafeDeleteUserEmailAccountFolderParams{MoveToParent: true, PageSize: 2})
+	if err != nil || !reflect.DeepEqual(deleted, []string{"Auto/News/Daily", "Auto/News"}) || len(folders["Auto"]) != 3 {
+		t.Error("Expected Auto/News deleted and messages moved to Auto; Got: ", deleted, "; ", folders, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+
+	// Only subfolders (by delimiter) are deleted, not folders sharing a prefix
+	deleted, err = cioLite.SafeDeleteUserEmailAccountFolder(ctx, "fakeUserID", "fakeLabel", "Auto/Shop", SafeDeleteUserEmailAccountFolderParams{})
+	if _, exists := folders["Auto/Shopping"]; err != nil || !reflect.DeepEqual(deleted, []string{"Auto/Shop"}) || !exists {
+		t.Error("Expected only Auto/Shop deleted; Got: ", deleted, "; With Error: ", err)
+	}
+
+	// Folders that do not exist are not an error
+	if deleted, err = cioLite.SafeDeleteUserEmailAccountFolder(ctx, "fakeUserID", "fakeLabel", "Auto/Shop", SafeDeleteUserEmailAccountFolderParams{}); err != nil || len(deleted) != 0 {
+		t.Error("Expected nothing deleted; Got: ", deleted, "; With Error: ", err)
+	}
+
+	// Special and top-level folders are refused
+	if _, err = cioLite.SafeDeleteUserEmailAccountFolder(ctx, "fakeUserID", "fakeLabel", "INBOX", SafeDeleteUserEmailAccountFolderParams{MoveToParent: true}); err == nil {
+		t.Error("Expected special folder error")
+	}
+	if _, err = cioLite.SafeDeleteUserEmailAccountFolder(ctx, "fakeUserID", "fakeLabel", "Auto", SafeDeleteUserEmailAccountFolderParams{MoveToParent: true}); err == nil {
+		t.Error("Expected top-level folder error")
+	}
+
+	// Rename and subscribe
+	response, err := cioLite.ModifyUserEmailAccountFolder("fakeUserID", "fakeLabel", "Auto/Shopping", ModifyUserEmailAccountFolderParams{NewName: "Auto/Shops", Subscribed: Flag(true)})
+	if _, exists := folders["Auto/Shops"]; err != nil || !response.Success || !exists {
+		t.Error("Expected folder renamed; Got: ", response, "; With Error: ", err)
+	}
+	if _, err = cioLite.ModifyUserEmailAccountFolder("fakeUserID", "fakeLabel", "Auto/Shops", ModifyUserEmailAccountFolderParams{Delimiter: "/"}); err == nil {
+		t.Error("Expected validation error")
+	} else if _, ok := cioutil.AsValidationError(err); !ok {
+		t.Error("Expected validation error; Got: ", err)
+	}
+}
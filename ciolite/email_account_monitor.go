@@ -0,0 +1,337 @@
+package ciolite
+
+// Health monitor that supports: https://context.io/docs/lite/users/email_accounts
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/contextio/contextio-go/cioutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMonitorInterval is the default interval between scans of an EmailAccountMonitor
+	DefaultMonitorInterval = 5 * time.Minute
+
+	// DefaultMonitorStuckAfter is the default duration an email account may stay unhealthy before it is stuck
+	DefaultMonitorStuckAfter = time.Hour
+
+	// DefaultMonitorStatusCheckInterval is the default first interval between gets of an email account after forcing a status check
+	DefaultMonitorStatusCheckInterval = 2 * time.Second
+
+	// DefaultMonitorStatusCheckAttempts is the default number of gets of an email account after forcing a status check
+	DefaultMonitorStatusCheckAttempts = 3
+)
+
+// EmailAccountEventType is the type of an EmailAccountEvent
+type EmailAccountEventType string
+
+const (
+	// EmailAccountBecameInvalid is emitted when an email account's status changes from OK
+	// (or is first seen) to any other status
+	EmailAccountBecameInvalid EmailAccountEventType = "became_invalid"
+
+	// EmailAccountRecovered is emitted when an email account that became invalid returns to OK status
+	EmailAccountRecovered EmailAccountEventType = "recovered"
+
+	// EmailAccountStuck is emitted once when an email account has not been OK for longer than StuckAfter
+	EmailAccountStuck EmailAccountEventType = "stuck"
+)
+
+// EmailAccountEvent is given to the EmailAccountMonitor Handler when an email account's health changes
+type EmailAccountEvent struct {
+	Type   EmailAccountEventType
+	UserID string
+
+	// Status and PreviousStatus are the current and previous status of the email account,
+	// with PreviousStatus empty if first seen.
+	Status         string
+	PreviousStatus string
+
+	// Since is when the email account was first seen in its current health
+	Since time.Time
+
+	EmailAccount GetUsersEmailAccountsResponse
+}
+
+// emailAccountHealth is the tracked health of an email account
+type emailAccountHealth struct {
+	status  string
+	since   time.Time
+	alerted bool
+	stuck   bool
+}
+
+// EmailAccountMonitor periodically scans the email accounts of users, tracks their status over time,
+// and emits events to the Handler when they become invalid, recover, or are stuck.
+type EmailAccountMonitor struct {
+	CioLite CioLite
+
+	// Required: invoked with each event, after each scan
+	Handler func(event EmailAccountEvent)
+
+	// Optional: invoked with any error scanning (defaults to ignoring errors)
+	OnError func(err error)
+
+	// Optional: defaults to DefaultMonitorInterval and DefaultMonitorStuckAfter
+	Interval   time.Duration
+	StuckAfter time.Duration
+
+	// Optional: only scan these users (defaults to all users, from GetUsers filtered by Users)
+	UserIDs []string
+	Users   GetUsersParams
+
+	// Optional: filter the email accounts of each user (such as StatusOK "0" to only return unhealthy accounts)
+	EmailAccounts GetUserEmailAccountsParams
+
+	// Optional: call ModifyUserEmailAccount with ForceStatusCheck, and get the email account again,
+	// before emitting a became invalid or stuck event.
+	ForceStatusCheck bool
+
+	// Optional: as CIO may check the status in the background, the email account is got up to StatusCheckAttempts times
+	// until its status changes, waiting StatusCheckInterval (doubling each time) between them.
+	// Defaults to DefaultMonitorStatusCheckAttempts and DefaultMonitorStatusCheckInterval.
+	StatusCheckAttempts int
+	StatusCheckInterval time.Duration
+
+	// Optional: the current time (defaults to time.Now)
+	Now func() time.Time
+
+	mu     sync.Mutex
+	health map[string]map[string]*emailAccountHealth
+}
+
+// Run scans immediately and then every Interval, until the context is done, returning the context's error
+func (monitor *EmailAccountMonitor) Run(ctx context.Context) error {
+	interval := monitor.Interval
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := monitor.Scan(ctx); err != nil && monitor.OnError != nil && ctx.Err() == nil {
+			monitor.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan scans the email accounts of the users once, emitting any events to the Handler.
+// Scanning continues past errors with individual users, returning the first error encountered.
+func (monitor *EmailAccountMonitor) Scan(ctx context.Context) error {
+	monitor.mu.Lock()
+	events, err := monitor.scan(ctx, monitor.CioLite.WithContext(ctx))
+	monitor.mu.Unlock()
+
+	if monitor.Handler != nil {
+		for _, event := range events {
+			monitor.Handler(event)
+		}
+	}
+	return err
+}
+
+// scan scans the email accounts, returning the events
+func (monitor *EmailAccountMonitor) scan(ctx context.Context, cioLite CioLite) ([]EmailAccountEvent, error) {
+	if monitor.health == nil {
+		monitor.health = make(map[string]map[string]*emailAccountHealth)
+	}
+
+	userIDs := monitor.UserIDs
+	if userIDs == nil {
+		users, err := cioLite.GetAllUsers(monitor.Users)
+		if err != nil {
+			return nil, errors.Wrap(err, "CIO: Unable to list users")
+		}
+		for _, user := range users {
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+
+	var events []EmailAccountEvent
+	var firstErr error
+
+	// Users no longer scanned are no longer tracked
+	scanned := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		scanned[userID] = true
+	}
+	for userID := range monitor.health {
+		if !scanned[userID] {
+			delete(monitor.health, userID)
+		}
+	}
+
+	for _, userID := range userIDs {
+		emailAccounts, err := cioLite.GetUserEmailAccounts(userID, monitor.EmailAccounts)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "CIO: Unable to list email accounts for user %s", userID)
+			}
+			continue
+		}
+
+		seen := make(map[string]bool, len(emailAccounts))
+		for _, emailAccount := range emailAccounts {
+			seen[emailAccount.Label] = true
+			events = monitor.observe(ctx, cioLite, events, userID, emailAccount)
+		}
+
+		// Tracked email accounts filtered out of (or removed from) this scan are checked individually
+		for label := range monitor.health[userID] {
+			if seen[label] {
+				continue
+			}
+			emailAccount, err := cioLite.GetUserEmailAccount(userID, label)
+			if cioutil.ErrorStatusCode(err) == http.StatusNotFound {
+				delete(monitor.health[userID], label)
+				continue
+			}
+			if err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "CIO: Unable to get email account %s for user %s", label, userID)
+				}
+				continue
+			}
+			events = monitor.observe(ctx, cioLite, events, userID, emailAccount)
+		}
+	}
+
+	return events, firstErr
+}
+
+// observe updates the tracked health of the email account, appending any events
+func (monitor *EmailAccountMonitor) observe(ctx context.Context, cioLite CioLite, events []EmailAccountEvent, userID string, emailAccount GetUsersEmailAccountsResponse) []EmailAccountEvent {
+	if monitor.health[userID] == nil {
+		monitor.health[userID] = make(map[string]*emailAccountHealth)
+	}
+	health, tracked := monitor.health[userID][emailAccount.Label]
+	now := monitor.now()
+
+	if !tracked {
+		health = &emailAccountHealth{status: "OK", since: now}
+		monitor.health[userID][emailAccount.Label] = health
+		if emailAccount.Status == "OK" {
+			return events
+		}
+		health.status = ""
+	}
+
+	previousStatus := health.status
+	event := EmailAccountEvent{UserID: userID, Status: emailAccount.Status, PreviousStatus: previousStatus, EmailAccount: emailAccount}
+
+	// Healthy
+	if emailAccount.Status == "OK" {
+		if previousStatus != "OK" {
+			if health.alerted {
+				event.Type = EmailAccountRecovered
+				event.Since = now
+				events = append(events, event)
+			}
+			*health = emailAccountHealth{status: "OK", since: now}
+		}
+		return events
+	}
+
+	// Unhealthy
+	if previousStatus == "OK" {
+		health.since = now
+	}
+	health.status = emailAccount.Status
+
+	stuckAfter := monitor.StuckAfter
+	if stuckAfter <= 0 {
+		stuckAfter = DefaultMonitorStuckAfter
+	}
+
+	var eventType EmailAccountEventType
+	if !health.alerted {
+		eventType = EmailAccountBecameInvalid
+	} else if !health.stuck && now.Sub(health.since) > stuckAfter {
+		eventType = EmailAccountStuck
+	} else {
+		return events
+	}
+
+	// Give CIO a chance to reconnect before alerting
+	if monitor.ForceStatusCheck {
+		checked, err := monitor.forceStatusCheck(ctx, cioLite, userID, emailAccount)
+		if err == nil && checked.Status == "OK" {
+			if health.alerted {
+				event.Type = EmailAccountRecovered
+				event.Status = checked.Status
+				event.Since = now
+				event.EmailAccount = checked
+				events = append(events, event)
+			}
+			*health = emailAccountHealth{status: "OK", since: now}
+			return events
+		}
+		if err == nil {
+			health.status = checked.Status
+			event.Status = checked.Status
+			event.EmailAccount = checked
+		}
+	}
+
+	if eventType == EmailAccountBecameInvalid {
+		health.alerted = true
+	} else {
+		health.stuck = true
+	}
+	event.Type = eventType
+	event.Since = health.since
+	return append(events, event)
+}
+
+// forceStatusCheck forces CIO to check the status of the email account, then gets it again,
+// with backoff, until its status changes or StatusCheckAttempts is reached
+func (monitor *EmailAccountMonitor) forceStatusCheck(ctx context.Context, cioLite CioLite, userID string, emailAccount GetUsersEmailAccountsResponse) (GetUsersEmailAccountsResponse, error) {
+	_, err := cioLite.ModifyUserEmailAccount(userID, emailAccount.Label, ModifyUserEmailAccountParams{ForceStatusCheck: true})
+	if err != nil {
+		return GetUsersEmailAccountsResponse{}, err
+	}
+
+	attempts := monitor.StatusCheckAttempts
+	if attempts <= 0 {
+		attempts = DefaultMonitorStatusCheckAttempts
+	}
+	interval := monitor.StatusCheckInterval
+	if interval <= 0 {
+		interval = DefaultMonitorStatusCheckInterval
+	}
+
+	for attempt := 1; ; attempt++ {
+		checked, err := cioLite.GetUserEmailAccount(userID, emailAccount.Label)
+		if err != nil || checked.Status != emailAccount.Status || attempt >= attempts {
+			return checked, err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return checked, errors.Wrap(ctx.Err(), "CIO: Stopped waiting for status check")
+		case <-timer.C:
+		}
+		interval *= 2
+	}
+}
+
+// now returns the current time
+func (monitor *EmailAccountMonitor) now() time.Time {
+	if monitor.Now != nil {
+		return monitor.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,122 @@
+package ciolite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSimulatedEmailAccountMonitor tests the became invalid, stuck, and recovered events of EmailAccountMonitor
+func TestSimulatedEmailAccountMonitor(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	var mu sync.Mutex
+	statuses := map[string]string{"a": "OK", "b": "INVALID_CREDENTIALS"}
+	forced := 0
+	checking := false
+
+	writeAccount := func(w http.ResponseWriter, label string) {
+		Must(json.NewEncoder(w).Encode(GetUsersEmailAccountsResponse{Label: label, Status: statuses[label]}))
+	}
+
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		Must(json.NewEncoder(w).Encode([]GetUsersResponse{{ID: "user1"}}))
+	})
+	mux.HandleFunc("/users/user1/email_accounts", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		Must(json.NewEncoder(w).Encode([]GetUsersEmailAccountsResponse{{Label: "a", Status: statuses["a"]}, {Label: "b", Status: statuses["b"]}}))
+	})
+	mux.HandleFunc("/users/user1/email_accounts/a", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == "POST" {
+			Must(r.ParseForm())
+			if r.PostForm.Get("force_status_check") == "1" {
+				forced++
+				checking = true
+			}
+			Must(json.NewEncoder(w).Encode(ModifyEmailAccountResponse{Success: true}))
+			return
+		}
+		writeAccount(w, "a")
+
+		// The forced status check completes in the background, after the next get
+		if checking {
+			checking = false
+			statuses["a"] = "OK"
+		}
+	})
+
+	now := time.Unix(1500000000, 0)
+	var events []EmailAccountEvent
+	monitor := &EmailAccountMonitor{
+		CioLite:    cioLite,
+		StuckAfter: time.Hour,
+		Handler:    func(event EmailAccountEvent) { events = append(events, event) },
+		Now:        func() time.Time { return now },
+	}
+
+	scan := func() []EmailAccountEvent {
+		events = nil
+		if err := monitor.Scan(context.Background()); err != nil {
+			t.Error("Unexpected scan error: ", err, "; With Log: ", logger.String())
+		}
+		return events
+	}
+
+	// First seen invalid
+	if events := scan(); len(events) != 1 || events[0].Type != EmailAccountBecameInvalid ||
+		events[0].EmailAccount.Label != "b" || events[0].PreviousStatus != "" || !events[0].Since.Equal(now) {
+		t.Error("Expected b to become invalid; Got: ", events)
+	}
+
+	// No change
+	now = now.Add(30 * time.Minute)
+	if events := scan(); len(events) != 0 {
+		t.Error("Expected no events; Got: ", events)
+	}
+
+	// Stuck, only once
+	now = now.Add(time.Hour)
+	if events := scan(); len(events) != 1 || events[0].Type != EmailAccountStuck || !events[0].Since.Equal(now.Add(-90*time.Minute)) {
+		t.Error("Expected b to be stuck; Got: ", events)
+	}
+	now = now.Add(time.Hour)
+	if events := scan(); len(events) != 0 {
+		t.Error("Expected no events; Got: ", events)
+	}
+
+	// Recovered
+	mu.Lock()
+	statuses["b"] = "OK"
+	mu.Unlock()
+	if events := scan(); len(events) != 1 || events[0].Type != EmailAccountRecovered || events[0].PreviousStatus != "INVALID_CREDENTIALS" {
+		t.Error("Expected b to recover; Got: ", events)
+	}
+
+	// Invalid, but recovers when forced to check status, so no alert
+	monitor.ForceStatusCheck = true
+	monitor.StatusCheckInterval = time.Millisecond
+	mu.Lock()
+	statuses["a"] = "CONNECTION_IMPOSSIBLE"
+	mu.Unlock()
+	events = scan()
+	mu.Lock()
+	if len(events) != 0 || forced != 1 {
+		t.Error("Expected forced status check without events; Got: ", events, forced)
+	}
+	mu.Unlock()
+
+	// Users no longer scanned are no longer tracked
+	monitor.UserIDs = []string{}
+	if events := scan(); len(events) != 0 || len(monitor.health) != 0 {
+		t.Error("Expected no tracked users; Got: ", monitor.health)
+	}
+}
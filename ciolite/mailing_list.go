@@ -0,0 +1,259 @@
+package ciolite
+
+// Mailing list and unsubscribe header parsing for: https://context.io/docs/lite/users/email_accounts/folders/messages
+// and: https://context.io/docs/lite/users/email_accounts/folders/messages/headers
+// 	https://tools.ietf.org/html/rfc2369 (List-Unsubscribe and List-Post)
+// 	https://tools.ietf.org/html/rfc2919 (List-Id)
+// 	https://tools.ietf.org/html/rfc8058 (List-Unsubscribe-Post one-click)
+
+import (
+	"mime"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ListUnsubscribeOneClick is the List-Unsubscribe-Post value (and the body to POST to the
+// https List-Unsubscribe URL) of a message that supports one-click unsubscribe
+const ListUnsubscribeOneClick = "List-Unsubscribe=One-Click"
+
+// SenderIdentity is the sender of a message, with the email address and domain lowercased
+type SenderIdentity struct {
+	Email  string
+	Name   string
+	Domain string
+}
+
+// UnsubscribeMailto is a mailto List-Unsubscribe URI: the message to send to unsubscribe
+type UnsubscribeMailto struct {
+	Address string
+	Subject string
+	Body    string
+}
+
+// MailingList is the mailing list and unsubscribe information of a message,
+// parsed by ParseMailingList or ParseMailingListHeaders.
+type MailingList struct {
+	Sender SenderIdentity
+
+	// ID and Name are from List-Id (such as "Announcements <announce.example.com>")
+	ID   string
+	Name string
+
+	// Post is the address from List-Post, and Announce is true if List-Post is NO (posting is not allowed)
+	Post     string
+	Announce bool
+
+	UnsubscribeMailto []UnsubscribeMailto
+	UnsubscribeURLs   []string
+
+	// OneClick is true if List-Unsubscribe-Post is List-Unsubscribe=One-Click and there is an https UnsubscribeURL
+	OneClick bool
+}
+
+// IsList returns true if the message has any mailing list headers
+func (list MailingList) IsList() bool {
+	return len(list.ID) > 0 || len(list.Post) > 0 || list.Announce || list.CanUnsubscribe()
+}
+
+// CanUnsubscribe returns true if the message has a mailto or http(s) List-Unsubscribe URI
+func (list MailingList) CanUnsubscribe() bool {
+	return len(list.UnsubscribeMailto) > 0 || len(list.UnsubscribeURLs) > 0
+}
+
+// OneClickURL returns the https URL to POST ListUnsubscribeOneClick to, or an empty string if not OneClick
+func (list MailingList) OneClickURL() string {
+	if !list.OneClick {
+		return ""
+	}
+	return firstHTTPS(list.UnsubscribeURLs)
+}
+
+// ParseMailingList returns the mailing list information from the ListHeaders of a message
+// (each in the form "List-Unsubscribe: <...>"), with the sender from its From (or Sender) address.
+func ParseMailingList(message GetUsersEmailAccountFolderMessagesResponse) MailingList {
+	headers := make(map[string][]string)
+	for _, header := range message.ListHeaders {
+		colon := strings.Index(header, ":")
+		if colon <= 0 {
+			continue
+		}
+		name := strings.TrimSpace(header[:colon])
+		headers[name] = append(headers[name], strings.TrimSpace(header[colon+1:]))
+	}
+
+	list := parseMailingList(headers)
+
+	addresses := message.Addresses.From
+	if len(addresses) == 0 {
+		addresses = message.Addresses.Sender
+	}
+	if len(addresses) > 0 {
+		list.Sender = newSenderIdentity(addresses[0].Email, addresses[0].Name)
+	}
+	return list
+}
+
+// ParseMailingListHeaders returns the mailing list information from the complete headers of a message,
+// with the sender from its From (or Sender) header.
+func ParseMailingListHeaders(headers GetUserEmailAccountsFolderMessageHeadersResponse) MailingList {
+	list := parseMailingList(headers.Headers)
+
+	for _, name := range []string{"From", "Sender"} {
+		for _, value := range headers.Headers.Values(name) {
+			if address, err := mail.ParseAddress(unfoldHeader(value)); err == nil {
+				list.Sender = newSenderIdentity(address.Address, address.Name)
+				return list
+			}
+		}
+	}
+	return list
+}
+
+// parseMailingList parses the list headers, matching header names case-insensitively
+func parseMailingList(headers map[string][]string) MailingList {
+	var list MailingList
+
+	for _, value := range headerValues(headers, "List-Id") {
+		list.ID, list.Name = parseListID(unfoldHeader(value))
+		if len(list.ID) > 0 {
+			break
+		}
+	}
+
+	for _, value := range headerValues(headers, "List-Post") {
+		uris := bracketedURIs(unfoldHeader(value))
+		if len(uris) == 0 && strings.HasPrefix(strings.ToUpper(unfoldHeader(value)), "NO") {
+			list.Announce = true
+			continue
+		}
+		for _, uri := range uris {
+			if mailto, ok := parseMailto(uri); ok && len(list.Post) == 0 {
+				list.Post = mailto.Address
+			}
+		}
+	}
+
+	for _, value := range headerValues(headers, "List-Unsubscribe") {
+		for _, uri := range bracketedURIs(unfoldHeader(value)) {
+			if mailto, ok := parseMailto(uri); ok {
+				list.UnsubscribeMailto = append(list.UnsubscribeMailto, mailto)
+				continue
+			}
+			if parsed, err := url.Parse(uri); err == nil && (parsed.Scheme == "https" || parsed.Scheme == "http") && len(parsed.Host) > 0 {
+				list.UnsubscribeURLs = append(list.UnsubscribeURLs, uri)
+			}
+		}
+	}
+
+	for _, value := range headerValues(headers, "List-Unsubscribe-Post") {
+		if strings.EqualFold(strings.TrimSpace(unfoldHeader(value)), ListUnsubscribeOneClick) && len(firstHTTPS(list.UnsubscribeURLs)) > 0 {
+			list.OneClick = true
+		}
+	}
+
+	return list
+}
+
+// parseListID returns the list label and the (RFC 2047 decoded) description of a List-Id header
+func parseListID(value string) (string, string) {
+	open := strings.LastIndex(value, "<")
+	closing := strings.LastIndex(value, ">")
+	if open < 0 || closing < open {
+		return strings.ToLower(strings.TrimSpace(value)), ""
+	}
+
+	name := strings.Trim(strings.TrimSpace(value[:open]), `"`)
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	return strings.ToLower(strings.TrimSpace(value[open+1 : closing])), name
+}
+
+// parseMailto returns the address, subject, and body of a mailto URI
+func parseMailto(uri string) (UnsubscribeMailto, bool) {
+	parsed, err := url.Parse(uri)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "mailto") {
+		return UnsubscribeMailto{}, false
+	}
+
+	address, err := url.PathUnescape(parsed.Opaque)
+	if err != nil || len(address) == 0 {
+		return UnsubscribeMailto{}, false
+	}
+	mailto := UnsubscribeMailto{Address: address}
+
+	// mailto header names are case-insensitive
+	for key, values := range parsed.Query() {
+		switch strings.ToLower(key) {
+		case "subject":
+			mailto.Subject = values[0]
+		case "body":
+			mailto.Body = values[0]
+		}
+	}
+	return mailto, true
+}
+
+// bracketedURIs returns the URIs within angle brackets, with any whitespace (from folding) removed
+func bracketedURIs(value string) []string {
+	var uris []string
+	for {
+		open := strings.Index(value, "<")
+		if open < 0 {
+			return uris
+		}
+		closing := strings.Index(value[open:], ">")
+		if closing < 0 {
+			return uris
+		}
+		if uri := strings.Join(strings.Fields(value[open+1:open+closing]), ""); len(uri) > 0 {
+			uris = append(uris, uri)
+		}
+		value = value[open+closing+1:]
+	}
+}
+
+// headerValues returns the values of the header, matching the name case-insensitively.
+// Values under different casings of the name are merged in the sorted order of the names, so the order is stable.
+func headerValues(headers map[string][]string, name string) []string {
+	var keys []string
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var values []string
+	for _, key := range keys {
+		values = append(values, headers[key]...)
+	}
+	return values
+}
+
+// unfoldHeader joins the lines of a folded header value
+func unfoldHeader(value string) string {
+	return strings.TrimSpace(strings.NewReplacer("\r\n", "", "\n", "", "\r", "").Replace(value))
+}
+
+// newSenderIdentity returns the SenderIdentity of the email address
+func newSenderIdentity(email string, name string) SenderIdentity {
+	email = strings.ToLower(strings.TrimSpace(email))
+	identity := SenderIdentity{Email: email, Name: strings.TrimSpace(name)}
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		identity.Domain = email[at+1:]
+	}
+	return identity
+}
+
+// firstHTTPS returns the first https URL, or an empty string if none
+func firstHTTPS(urls []string) string {
+	for _, u := range urls {
+		if strings.HasPrefix(strings.ToLower(u), "https:") {
+			return u
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package ciolite
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// TestParseMailingList tests ParseMailingList with the list headers of a message
+func TestParseMailingList(t *testing.T) {
+	t.Parallel()
+
+	var message GetUsersEmailAccountFolderMessagesResponse
+	Must(json.Unmarshal([]byte(`{
+		"list_headers": [
+			"List-Id: \"Weekly News\" <News.Example.com>",
+			"list-unsubscribe: <mailto:unsub@example.com?Subject=unsubscribe%20me>,\r\n <https://example.com/unsub?u=123>",
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+			"List-Post: NO (posting not allowed)",
+			"Not a header"
+		],
+		"addresses": {"from": [{"email": "News@Example.com", "name": "Example News"}]}
+	}`), &message))
+
+	expected := MailingList{
+		Sender:            SenderIdentity{Email: "news@example.com", Name: "Example News", Domain: "example.com"},
+		ID:                "news.example.com",
+		Name:              "Weekly News",
+		Announce:          true,
+		UnsubscribeMailto: []UnsubscribeMailto{{Address: "unsub@example.com", Subject: "unsubscribe me"}},
+		UnsubscribeURLs:   []string{"https://example.com/unsub?u=123"},
+		OneClick:          true,
+	}
+
+	list := ParseMailingList(message)
+	if !reflect.DeepEqual(list, expected) {
+		t.Error("Expected: ", expected, "; Got: ", list)
+	}
+	if !list.IsList() || !list.CanUnsubscribe() || list.OneClickURL() != "https://example.com/unsub?u=123" {
+		t.Error("Expected one-click unsubscribable list; Got: ", list)
+	}
+
+	// One-click requires an https URL
+	message.ListHeaders = []string{
+		"List-Unsubscribe: <http://example.com/unsub>",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+	}
+	if list := ParseMailingList(message); list.OneClick || list.OneClickURL() != "" || !list.CanUnsubscribe() {
+		t.Error("Expected unsubscribable list without one-click; Got: ", list)
+	}
+
+	// Not a list
+	message.ListHeaders = nil
+	if list := ParseMailingList(message); list.IsList() || list.CanUnsubscribe() || list.Sender.Domain != "example.com" {
+		t.Error("Expected no list; Got: ", list)
+	}
+}
+
+// TestSimulatedParseMailingListHeaders tests ParseMailingListHeaders with the headers from a simulated server
+func TestSimulatedParseMailingListHeaders(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/fakeMessageID/headers", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `{
+			"resource_url": "https://api.context.io/lite/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/fakeMessageID/headers",
+			"headers": {
+				"from": ["=?UTF-8?Q?Caf=C3=A9_Club?= <Club@Lists.Example.org>"],
+				"list-id": ["<club.lists.example.org>"],
+				"list-post": ["<mailto:club@lists.example.org>"],
+				"LIST-UNSUBSCRIBE": ["<mailto:club-leave@lists.example.org>"]
+			}
+		}`)
+		Must(err)
+	})
+
+	headers, err := cioLite.GetUserEmailAccountsFolderMessageHeaders("fakeUserID", "fakeLabel", "INBOX", "fakeMessageID", GetUserEmailAccountsFolderMessageHeadersParams{})
+
+	expected := MailingList{
+		Sender:            SenderIdentity{Email: "club@lists.example.org", Name: "Café Club", Domain: "lists.example.org"},
+		ID:                "club.lists.example.org",
+		Post:              "club@lists.example.org",
+		UnsubscribeMailto: []UnsubscribeMailto{{Address: "club-leave@lists.example.org"}},
+	}
+
+	list := ParseMailingListHeaders(headers)
+	if err != nil || !reflect.DeepEqual(list, expected) {
+		t.Error("Expected: ", expected, "; Got: ", list, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+	if !list.IsList() || list.OneClick {
+		t.Error("Expected list without one-click; Got: ", list)
+	}
+}
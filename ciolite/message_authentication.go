@@ -0,0 +1,267 @@
+package ciolite
+
+// Authentication analysis of messages from: https://context.io/docs/lite/users/email_accounts/folders/messages/headers
+// and: https://context.io/docs/lite/users/email_accounts/folders/messages/raw
+// 	https://tools.ietf.org/html/rfc8601 (Authentication-Results)
+// 	https://tools.ietf.org/html/rfc7208#section-9.1 (Received-SPF)
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// AuthenticationStatus is the lowercased result of an authentication method, or the verdict of a message
+type AuthenticationStatus string
+
+const (
+	// AuthPass means the message passed authentication
+	AuthPass AuthenticationStatus = "pass"
+
+	// AuthFail means the message failed authentication
+	AuthFail AuthenticationStatus = "fail"
+
+	// AuthSoftFail means the message failed SPF, but the domain does not assert the failure strongly
+	AuthSoftFail AuthenticationStatus = "softfail"
+
+	// AuthNeutral means the domain makes no assertion
+	AuthNeutral AuthenticationStatus = "neutral"
+
+	// AuthNone means there was no authentication result (or not enough to reach a verdict)
+	AuthNone AuthenticationStatus = "none"
+
+	// AuthTempError means authentication could not be completed due to a temporary error
+	AuthTempError AuthenticationStatus = "temperror"
+
+	// AuthPermError means authentication could not be completed due to a malformed record or signature
+	AuthPermError AuthenticationStatus = "permerror"
+)
+
+// MessageAuthentication is the SPF, DKIM, and DMARC authentication of a message, and its overall Verdict.
+// The Verdict is AuthPermError if the message has more than one From header; otherwise AuthPass if DMARC passed,
+// or if SPF or DKIM passed for a domain aligned with the From domain; AuthFail if DMARC, SPF, or DKIM failed
+// (without an aligned pass); AuthSoftFail if SPF soft failed; otherwise AuthNone.
+type MessageAuthentication struct {
+	// AuthServID is the authserv-id of the first trusted Authentication-Results header, if any
+	AuthServID string
+
+	FromDomain string
+
+	// FromHeaders is the number of From headers (with more than one, FromDomain is empty and the Verdict is AuthPermError)
+	FromHeaders int
+
+	SPF       AuthenticationStatus
+	SPFDomain string
+
+	// DKIMDomains are the domains of the passing DKIM signatures
+	DKIM        AuthenticationStatus
+	DKIMDomains []string
+
+	DMARC AuthenticationStatus
+
+	// DKIMVerifications are the offline DKIM verifications (only for raw messages, with a KeyResolver)
+	DKIMVerifications []DKIMVerification
+
+	Verdict AuthenticationStatus
+}
+
+// Authenticated returns true if the Verdict is AuthPass
+func (auth MessageAuthentication) Authenticated() bool {
+	return auth.Verdict == AuthPass
+}
+
+// AuthenticationAnalyzer analyzes the Authentication-Results and Received-SPF headers of messages,
+// optionally verifying the DKIM signatures of raw messages offline.
+// No headers are trusted unless TrustedAuthServIDs or TrustTopHeaders is set, as any header may have been added by the sender.
+type AuthenticationAnalyzer struct {
+	// Optional: trust Authentication-Results headers with these authserv-ids (those added by the receiving servers)
+	TrustedAuthServIDs []string
+
+	// Optional: trust the top-most Authentication-Results header, and the top-most Received-SPF header without a trusted result.
+	// This is only safe if the receiving server always adds both headers, otherwise the top-most may be forged by the sender.
+	TrustTopHeaders bool
+
+	// Optional: verify the DKIM signatures of raw messages, looking up keys with the KeyResolver
+	KeyResolver DKIMKeyResolver
+
+	// Optional: the current time, to check DKIM signature expiration against (defaults to time.Now)
+	Now func() time.Time
+}
+
+// AnalyzeHeaders returns the authentication of a message from its headers
+// (such as from GetUserEmailAccountsFolderMessageHeaders, or GetUserEmailAccountsFolderMessages with IncludeHeaders).
+func (analyzer AuthenticationAnalyzer) AnalyzeHeaders(headers MessageHeaders) MessageAuthentication {
+	auth := MessageAuthentication{SPF: AuthNone, DKIM: AuthNone, DMARC: AuthNone}
+
+	auth.FromHeaders = len(headers.Values("From"))
+	if from, _ := headers.AddressList("From"); len(from) > 0 && auth.FromHeaders == 1 {
+		auth.FromDomain = newSenderIdentity(from[0].Address, "").Domain
+	}
+
+	// Combine the results of the trusted Authentication-Results headers, the first result of each method winning
+	var spf, dmarc *AuthenticationResult
+	var dkim []AuthenticationResult
+	for i, results := range headers.AuthenticationResults() {
+		if !analyzer.trusts(results, i) {
+			continue
+		}
+		if len(auth.AuthServID) == 0 {
+			auth.AuthServID = results.AuthServID
+		}
+		for _, result := range results.Results {
+			result := result
+			switch result.Method {
+			case "spf":
+				if spf == nil {
+					spf = &result
+				}
+			case "dmarc":
+				if dmarc == nil {
+					dmarc = &result
+				}
+			case "dkim":
+				dkim = append(dkim, result)
+			}
+		}
+	}
+
+	if spf != nil {
+		auth.SPF = normalizeAuthenticationStatus(spf.Result)
+		auth.SPFDomain = domainOf(spf.Properties["smtp.mailfrom"])
+		if len(auth.SPFDomain) == 0 {
+			auth.SPFDomain = domainOf(spf.Properties["smtp.helo"])
+		}
+	} else if receivedSPF := unfoldHeader(headers.Get("Received-SPF")); len(receivedSPF) > 0 && analyzer.TrustTopHeaders {
+		// Received-SPF is only used without a trusted result, and only the top-most (added by the receiving server)
+		auth.SPF = normalizeAuthenticationStatus(upToSeparator(receivedSPF, " "))
+		tags := parseDKIMTags(stripHeaderComments(receivedSPF))
+		for key, value := range tags {
+			if strings.EqualFold(strings.TrimSpace(key[strings.LastIndex(key, " ")+1:]), "envelope-from") {
+				auth.SPFDomain = domainOf(strings.Trim(value, `"<>`))
+			}
+		}
+	}
+
+	for _, result := range dkim {
+		status := normalizeAuthenticationStatus(result.Result)
+		if status == AuthPass {
+			domain := strings.ToLower(result.Properties["header.d"])
+			if len(domain) == 0 {
+				domain = domainOf(result.Properties["header.i"])
+			}
+			auth.addDKIMPass(domain)
+		} else if auth.DKIM == AuthNone {
+			auth.DKIM = status
+		}
+	}
+
+	if dmarc != nil {
+		auth.DMARC = normalizeAuthenticationStatus(dmarc.Result)
+	}
+
+	auth.Verdict = auth.verdict()
+	return auth
+}
+
+// AnalyzeRawMessage returns the authentication of a raw message (such as from GetUserEmailAccountsFolderMessageRaw),
+// verifying its DKIM signatures offline if there is a KeyResolver. Returns an error if the headers can not be parsed.
+func (analyzer AuthenticationAnalyzer) AnalyzeRawMessage(ctx context.Context, raw string) (MessageAuthentication, error) {
+	headers, err := ParseRawHeaders(raw)
+	if err != nil {
+		return MessageAuthentication{SPF: AuthNone, DKIM: AuthNone, DMARC: AuthNone, Verdict: AuthNone}, err
+	}
+	auth := analyzer.AnalyzeHeaders(headers)
+
+	if analyzer.KeyResolver != nil {
+		now := time.Now()
+		if analyzer.Now != nil {
+			now = analyzer.Now()
+		}
+		auth.DKIMVerifications = VerifyDKIM(ctx, raw, analyzer.KeyResolver, now)
+		for _, verification := range auth.DKIMVerifications {
+			if verification.Status == AuthPass {
+				auth.addDKIMPass(verification.Domain)
+			} else if auth.DKIM == AuthNone {
+				auth.DKIM = verification.Status
+			}
+		}
+		auth.Verdict = auth.verdict()
+	}
+	return auth, nil
+}
+
+// trusts returns true if the Authentication-Results header (the index-th from the top) is trusted
+func (analyzer AuthenticationAnalyzer) trusts(results AuthenticationResults, index int) bool {
+	return (analyzer.TrustTopHeaders && index == 0) || containsFoldString(analyzer.TrustedAuthServIDs, results.AuthServID)
+}
+
+// addDKIMPass records a passing DKIM signature of the domain
+func (auth *MessageAuthentication) addDKIMPass(domain string) {
+	auth.DKIM = AuthPass
+	if len(domain) > 0 && !containsFoldString(auth.DKIMDomains, domain) {
+		auth.DKIMDomains = append(auth.DKIMDomains, domain)
+	}
+}
+
+// verdict returns the overall verdict of the authentication
+func (auth MessageAuthentication) verdict() AuthenticationStatus {
+	if auth.FromHeaders > 1 {
+		return AuthPermError
+	}
+
+	switch auth.DMARC {
+	case AuthPass:
+		return AuthPass
+	case AuthFail:
+		return AuthFail
+	}
+
+	if auth.SPF == AuthPass && alignedDomains(auth.SPFDomain, auth.FromDomain) {
+		return AuthPass
+	}
+	for _, domain := range auth.DKIMDomains {
+		if alignedDomains(domain, auth.FromDomain) {
+			return AuthPass
+		}
+	}
+
+	switch {
+	case auth.SPF == AuthFail || auth.DKIM == AuthFail:
+		return AuthFail
+	case auth.SPF == AuthSoftFail:
+		return AuthSoftFail
+	}
+	return AuthNone
+}
+
+// normalizeAuthenticationStatus returns the AuthenticationStatus of a result, mapping legacy names
+func normalizeAuthenticationStatus(result string) AuthenticationStatus {
+	switch result = strings.ToLower(strings.TrimSpace(result)); result {
+	case "":
+		return AuthNone
+	case "hardfail":
+		return AuthFail
+	case "temperror", "error":
+		return AuthTempError
+	}
+	return AuthenticationStatus(result)
+}
+
+// alignedDomains returns true if the domains are equal, or one is a subdomain of the other
+// (an approximation of DMARC relaxed alignment, without the public suffix list)
+func alignedDomains(a string, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	return a == b || strings.HasSuffix(a, "."+b) || strings.HasSuffix(b, "."+a)
+}
+
+// domainOf returns the lowercased domain of an email address (or "@domain"), or the value itself if it is a domain
+func domainOf(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if at := strings.LastIndex(value, "@"); at >= 0 {
+		return value[at+1:]
+	}
+	return value
+}
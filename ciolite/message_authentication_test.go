@@ -0,0 +1,255 @@
+package ciolite
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TestAnalyzeHeaders tests AnalyzeHeaders with Authentication-Results and Received-SPF headers
+func TestAnalyzeHeaders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		headers  MessageHeaders
+		analyzer AuthenticationAnalyzer
+		expected MessageAuthentication
+	}{
+		{
+			name: "dmarc pass",
+			headers: MessageHeaders{
+				"From":                   {"News <news@mail.example.com>"},
+				"Authentication-Results": {"mx.example.org; spf=pass smtp.mailfrom=bounce@example.com; dkim=pass header.i=@example.com; dmarc=pass header.from=mail.example.com"},
+			},
+			analyzer: AuthenticationAnalyzer{TrustTopHeaders: true},
+			expected: MessageAuthentication{AuthServID: "mx.example.org", FromDomain: "mail.example.com", SPF: AuthPass, SPFDomain: "example.com",
+				DKIM: AuthPass, DKIMDomains: []string{"example.com"}, DMARC: AuthPass, Verdict: AuthPass},
+		},
+		{
+			name: "forged lower header is not trusted",
+			headers: MessageHeaders{
+				"From": {"ceo@example.com"},
+				"Authentication-Results": {
+					"mx.example.org; spf=fail smtp.mailfrom=evil.example.net; dkim=none",
+					"mx.example.org; spf=pass smtp.mailfrom=example.com; dkim=pass header.d=example.com; dmarc=pass",
+				},
+			},
+			analyzer: AuthenticationAnalyzer{TrustTopHeaders: true},
+			expected: MessageAuthentication{AuthServID: "mx.example.org", FromDomain: "example.com", SPF: AuthFail, SPFDomain: "evil.example.net",
+				DKIM: AuthNone, DMARC: AuthNone, Verdict: AuthFail},
+		},
+		{
+			name: "trusted authserv-ids",
+			headers: MessageHeaders{
+				"from": {"ceo@example.com"},
+				"authentication-results": {
+					"forged.example.net; dmarc=pass",
+					"mx.example.org; dkim=fail header.d=example.com; dkim=pass header.d=other.example.net",
+				},
+			},
+			analyzer: AuthenticationAnalyzer{TrustedAuthServIDs: []string{"MX.example.org"}},
+			expected: MessageAuthentication{AuthServID: "mx.example.org", FromDomain: "example.com", SPF: AuthNone,
+				DKIM: AuthPass, DKIMDomains: []string{"other.example.net"}, DMARC: AuthNone, Verdict: AuthNone},
+		},
+		{
+			name: "received-spf",
+			headers: MessageHeaders{
+				"From":         {"alerts@example.com"},
+				"Received-SPF": {"SoftFail (mx.example.org: domain of transitioning bounce@example.com does not designate 192.0.2.1 as permitted sender)\r\n client-ip=192.0.2.1; envelope-from=\"bounce@example.com\"; helo=mail.example.com;"},
+			},
+			analyzer: AuthenticationAnalyzer{TrustTopHeaders: true},
+			expected: MessageAuthentication{FromDomain: "example.com", SPF: AuthSoftFail, SPFDomain: "example.com", DKIM: AuthNone, DMARC: AuthNone, Verdict: AuthSoftFail},
+		},
+		{
+			name: "untrusted without configuration",
+			headers: MessageHeaders{
+				"From":                   {"ceo@example.com"},
+				"Authentication-Results": {"mx.example.org; spf=pass smtp.mailfrom=example.com; dmarc=pass"},
+				"Received-SPF":           {"Pass (mx.example.org: domain of ceo@example.com designates 192.0.2.1 as permitted sender) envelope-from=ceo@example.com;"},
+			},
+			expected: MessageAuthentication{FromDomain: "example.com", SPF: AuthNone, DKIM: AuthNone, DMARC: AuthNone, Verdict: AuthNone},
+		},
+		{
+			name: "multiple from headers",
+			headers: MessageHeaders{
+				"From":                   {"news@example.com", "ceo@victim.example.org"},
+				"Authentication-Results": {"mx.example.org; spf=pass smtp.mailfrom=example.com; dmarc=pass"},
+			},
+			analyzer: AuthenticationAnalyzer{TrustTopHeaders: true},
+			expected: MessageAuthentication{AuthServID: "mx.example.org", SPF: AuthPass, SPFDomain: "example.com", DKIM: AuthNone, DMARC: AuthPass, Verdict: AuthPermError},
+		},
+		{
+			name:     "no results",
+			headers:  MessageHeaders{},
+			expected: MessageAuthentication{SPF: AuthNone, DKIM: AuthNone, DMARC: AuthNone, Verdict: AuthNone},
+		},
+	}
+
+	for _, test := range tests {
+		auth := test.analyzer.AnalyzeHeaders(test.headers)
+		if !sameAuthentication(auth, test.expected) {
+			t.Error(test.name, "; Expected: ", test.expected, "; Got: ", auth)
+		}
+	}
+}
+
+// TestDKIMCanonicalization tests canonicalization with the examples of RFC 6376 section 3.4.6
+func TestDKIMCanonicalization(t *testing.T) {
+	t.Parallel()
+
+	fields, body := splitRawMessage("A: X\r\nB : Y\t\r\n\tZ  \r\n\r\n C \r\nD \t E\r\n\r\n\r\n")
+
+	relaxed := canonicalizeDKIMHeader(fields[0].raw, "relaxed") + canonicalizeDKIMHeader(fields[1].raw, "relaxed")
+	if relaxed != "a:X\r\nb:Y Z\r\n" {
+		t.Errorf("Expected relaxed headers; Got: %q", relaxed)
+	}
+	simple := canonicalizeDKIMHeader(fields[0].raw, "simple") + canonicalizeDKIMHeader(fields[1].raw, "simple")
+	if simple != "A: X\r\nB : Y\t\r\n\tZ  \r\n" {
+		t.Errorf("Expected simple headers; Got: %q", simple)
+	}
+
+	if relaxedBody := canonicalizeDKIMBody(body, "relaxed"); relaxedBody != " C\r\nD E\r\n" {
+		t.Errorf("Expected relaxed body; Got: %q", relaxedBody)
+	}
+	if simpleBody := canonicalizeDKIMBody(body, "simple"); simpleBody != " C \r\nD \t E\r\n" {
+		t.Errorf("Expected simple body; Got: %q", simpleBody)
+	}
+	if canonicalizeDKIMBody("", "simple") != "\r\n" || canonicalizeDKIMBody("\r\n", "relaxed") != "" {
+		t.Error("Expected empty body canonicalization")
+	}
+}
+
+// TestAnalyzeRawMessage tests AnalyzeRawMessage, verifying RSA and Ed25519 DKIM signatures offline
+func TestAnalyzeRawMessage(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Must(err)
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	Must(err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	Must(err)
+
+	keys := map[string]string{
+		"rsa._domainkey.example.com":     "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPublic),
+		"ed._domainkey.example.com":      "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPublic),
+		"revoked._domainkey.example.com": "v=DKIM1; p=",
+	}
+	resolver := DKIMKeyResolverFunc(func(ctx context.Context, selector string, domain string) (string, error) {
+		record, ok := keys[selector+"._domainkey."+domain]
+		if !ok {
+			return "", errors.New("no such host")
+		}
+		return record, nil
+	})
+
+	message := "From: Example <news@example.com>\r\nTo: me@example.org\r\nSubject:  Hello\r\n\tthere\r\n\r\nHi  there \r\n\r\n\r\n"
+	signed := signTestDKIM(message, "relaxed/relaxed", "rsa", func(digest []byte) []byte {
+		signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest)
+		Must(err)
+		return signature
+	})
+	signed = signTestDKIM(signed, "simple/simple", "ed", func(digest []byte) []byte {
+		return ed25519.Sign(edPrivate, digest)
+	})
+
+	analyzer := AuthenticationAnalyzer{KeyResolver: resolver}
+	auth, err := analyzer.AnalyzeRawMessage(context.Background(), signed)
+	if err != nil || len(auth.DKIMVerifications) != 2 || auth.DKIMVerifications[0].Status != AuthPass || auth.DKIMVerifications[1].Status != AuthPass ||
+		auth.DKIM != AuthPass || auth.FromDomain != "example.com" || !auth.Authenticated() {
+		t.Fatal("Expected 2 passing signatures; Got: ", auth, "; With Error: ", err)
+	}
+
+	// LF line endings (as CIO may return) still verify
+	if auth, _ := analyzer.AnalyzeRawMessage(context.Background(), strings.Replace(signed, "\r\n", "\n", -1)); !auth.Authenticated() {
+		t.Error("Expected LF message to pass; Got: ", auth)
+	}
+
+	// Tampering: relaxed canonicalization tolerates whitespace changes, simple does not
+	tampered, _ := analyzer.AnalyzeRawMessage(context.Background(), strings.Replace(signed, "Hi  there", "Hi there", 1))
+	if tampered.DKIMVerifications[0].Status != AuthFail || tampered.DKIMVerifications[1].Status != AuthPass {
+		t.Error("Expected simple fail and relaxed pass; Got: ", tampered.DKIMVerifications)
+	}
+	tampered, _ = analyzer.AnalyzeRawMessage(context.Background(), strings.Replace(signed, "Subject:  Hello", "Subject:  Goodbye", 1))
+	if tampered.DKIM != AuthFail || tampered.Verdict != AuthFail || len(tampered.DKIMDomains) != 0 {
+		t.Error("Expected failing signatures; Got: ", tampered)
+	}
+
+	// Key errors
+	for selector, expected := range map[string]AuthenticationStatus{"revoked": AuthPermError, "missing": AuthTempError} {
+		unverifiable := signTestDKIM(message, "relaxed/relaxed", selector, func(digest []byte) []byte { return digest })
+		auth, _ := analyzer.AnalyzeRawMessage(context.Background(), unverifiable)
+		if len(auth.DKIMVerifications) != 1 || auth.DKIMVerifications[0].Status != expected || auth.DKIMVerifications[0].Err == nil {
+			t.Error("Expected ", expected, " for selector ", selector, "; Got: ", auth.DKIMVerifications)
+		}
+	}
+
+	// Without a resolver, only the headers are analyzed
+	if auth, err := (AuthenticationAnalyzer{}).AnalyzeRawMessage(context.Background(), signed); err != nil || auth.DKIM != AuthNone || len(auth.DKIMVerifications) != 0 {
+		t.Error("Expected no verification; Got: ", auth, "; With Error: ", err)
+	}
+}
+
+// signTestDKIM prepends a DKIM-Signature for example.com with the selector to the raw message,
+// signing From, To, and Subject with sign (given the SHA-256 digest of the canonicalized headers)
+func signTestDKIM(raw string, canonicalization string, selector string, sign func(digest []byte) []byte) string {
+	fields, body := splitRawMessage(raw)
+	headerCanonicalization, bodyCanonicalization := upToSeparator(canonicalization, "/"), canonicalization[strings.Index(canonicalization, "/")+1:]
+
+	algorithm := "rsa-sha256"
+	if selector == "ed" {
+		algorithm = "ed25519-sha256"
+	}
+	bodyHash := sha256.Sum256([]byte(canonicalizeDKIMBody(body, bodyCanonicalization)))
+	signature := "DKIM-Signature: v=1; a=" + algorithm + "; c=" + canonicalization + "; d=example.com; s=" + selector + ";\r\n" +
+		"\th=From:To:Subject; bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) + ";\r\n\tb="
+
+	headerHash := sha256.New()
+	for _, name := range []string{"From", "To", "Subject"} {
+		for _, field := range fields {
+			if field.name == name {
+				headerHash.Write([]byte(canonicalizeDKIMHeader(field.raw, headerCanonicalization)))
+			}
+		}
+	}
+	headerHash.Write([]byte(strings.TrimSuffix(canonicalizeDKIMHeader(signature+"\r\n", headerCanonicalization), "\r\n")))
+
+	b := base64.StdEncoding.EncodeToString(sign(headerHash.Sum(nil)))
+	return signature + b[:len(b)/2] + "\r\n\t" + b[len(b)/2:] + "\r\n" + raw
+}
+
+// sameAuthentication compares MessageAuthentication, ignoring DKIMVerifications
+func sameAuthentication(a MessageAuthentication, b MessageAuthentication) bool {
+	return a.AuthServID == b.AuthServID && a.FromDomain == b.FromDomain && a.SPF == b.SPF && a.SPFDomain == b.SPFDomain &&
+		a.DKIM == b.DKIM && strings.Join(a.DKIMDomains, ",") == strings.Join(b.DKIMDomains, ",") && a.DMARC == b.DMARC && a.Verdict == b.Verdict
+}
+
+// TestDKIMExpiration tests that expired signatures fail, and malformed signatures are permanent errors
+func TestDKIMExpiration(t *testing.T) {
+	t.Parallel()
+
+	raw := "DKIM-Signature: v=1; a=rsa-sha256; d=example.com; s=rsa; h=From; x=1000; bh=AAAA; b=AAAA\r\nFrom: a@example.com\r\n\r\nbody\r\n"
+	verifications := VerifyDKIM(context.Background(), raw, DKIMKeyResolverFunc(nil), time.Unix(2000, 0))
+	if len(verifications) != 1 || verifications[0].Status != AuthFail || verifications[0].Domain != "example.com" {
+		t.Error("Expected expired signature to fail; Got: ", verifications)
+	}
+
+	for _, tags := range []string{"h=From; l=-1", "h=From; l=ten", "h=To:Subject"} {
+		raw := "DKIM-Signature: v=1; a=rsa-sha256; d=example.com; s=rsa; " + tags + "; bh=AAAA; b=AAAA\r\nFrom: a@example.com\r\n\r\nbody\r\n"
+		verifications := VerifyDKIM(context.Background(), raw, DKIMKeyResolverFunc(nil), time.Unix(2000, 0))
+		if len(verifications) != 1 || verifications[0].Status != AuthPermError || verifications[0].Err == nil {
+			t.Error("Expected permerror for ", tags, "; Got: ", verifications)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package ciolite
+
+// Body decoding that supports: https://context.io/docs/lite/users/email_accounts/folders/messages/body
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// DecodedContent returns the Content converted to UTF-8 from the declared Charset.
+// CIO usually returns Content already converted, and converted text (such as "café" labelled windows-1251)
+// can not be told apart from the original bytes, so only Latin-1 Content (ISO-8859-1 or windows-1252)
+// containing C1 control characters, which text does not contain, is decoded as the original bytes.
+// Any other Content is returned unchanged, along with an error if the Charset is unknown.
+func (body GetUserEmailAccountsFolderMessageBodyResponse) DecodedContent() (string, error) {
+	charset := strings.ToLower(strings.Trim(strings.TrimSpace(body.Charset), `"'`))
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return strings.ToValidUTF8(body.Content, "\uFFFD"), nil
+	}
+
+	enc, err := charsetEncoding(charset)
+	if err != nil {
+		return body.Content, err
+	}
+	if enc != charmap.Windows1252 && enc != charmap.ISO8859_1 {
+		return body.Content, nil
+	}
+
+	// Each character is a byte of the original content, unless CIO already converted it
+	raw := make([]byte, 0, len(body.Content))
+	unconverted := false
+	for _, r := range body.Content {
+		if r > 0xFF {
+			return body.Content, nil
+		}
+		if r >= 0x80 && r <= 0x9F {
+			unconverted = true
+		}
+		raw = append(raw, byte(r))
+	}
+	if !unconverted {
+		return body.Content, nil
+	}
+
+	// Decoded as windows-1252, as ISO-8859-1 labelled content with C1 bytes almost always is
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(raw)
+	if err != nil {
+		return body.Content, errors.Wrap(err, "CIO: Unable to decode body content from charset "+body.Charset)
+	}
+	return string(decoded), nil
+}
+
+// Text returns the decoded Content as plain text, converting it with HTMLToText if the Type is text/html
+func (body GetUserEmailAccountsFolderMessageBodyResponse) Text() (string, error) {
+	content, err := body.DecodedContent()
+	if body.IsHTML() {
+		return HTMLToText(content), err
+	}
+	return content, err
+}
+
+// IsHTML returns true if the Type is text/html
+func (body GetUserEmailAccountsFolderMessageBodyResponse) IsHTML() bool {
+	return strings.EqualFold(strings.TrimSpace(upToSeparator(body.Type, ";")), "text/html")
+}
+
+// BestMessageBody returns the best body variant of the type (text/plain or text/html, defaulting to text/plain),
+// falling back to the other text type, then to any body. Bodies without content are skipped.
+// Returns false if there are no bodies with content.
+func BestMessageBody(bodies []GetUserEmailAccountsFolderMessageBodyResponse, preferType string) (GetUserEmailAccountsFolderMessageBodyResponse, bool) {
+	if len(preferType) == 0 {
+		preferType = "text/plain"
+	}
+	types := []string{preferType, "text/plain", "text/html", ""}
+
+	for _, bodyType := range types {
+		for _, body := range bodies {
+			if len(body.Content) == 0 {
+				continue
+			}
+			if len(bodyType) == 0 || strings.EqualFold(strings.TrimSpace(upToSeparator(body.Type, ";")), bodyType) {
+				return body, true
+			}
+		}
+	}
+	return GetUserEmailAccountsFolderMessageBodyResponse{}, false
+}
+
+// MessageBodyText returns the best plain text rendering of the bodies of a message:
+// the text/plain body if there is one, otherwise the text/html body converted with HTMLToText.
+func MessageBodyText(bodies []GetUserEmailAccountsFolderMessageBodyResponse) (string, error) {
+	body, ok := BestMessageBody(bodies, "text/plain")
+	if !ok {
+		return "", nil
+	}
+	return body.Text()
+}
+
+// charsetEncoding returns the encoding of a charset, by its WHATWG label or IANA name
+func charsetEncoding(charset string) (encoding.Encoding, error) {
+	if enc, err := htmlindex.Get(charset); err == nil {
+		return enc, nil
+	}
+	if enc, err := ianaindex.MIME.Encoding(charset); err == nil && enc != nil {
+		return enc, nil
+	}
+	return nil, errors.New("CIO: Unknown body charset: " + charset)
+}
+
+// HTMLToText returns a plain text rendering of an HTML document: scripts, styles, and comments are removed,
+// block elements start new lines, list items are prefixed with "- ", link URLs follow their text in parentheses,
+// images are replaced by their alt text, entities are unescaped, and whitespace is collapsed (except in pre).
+func HTMLToText(document string) string {
+	var w htmlTextWriter
+	var href string
+	linkStart := -1
+
+	for len(document) > 0 {
+		open := strings.IndexByte(document, '<')
+		if open < 0 {
+			w.text(html.UnescapeString(document))
+			break
+		}
+		if open > 0 {
+			w.text(html.UnescapeString(document[:open]))
+			document = document[open:]
+		}
+
+		// Comments, doctypes, and processing instructions
+		if strings.HasPrefix(document, "<!--") {
+			end := strings.Index(document, "-->")
+			if end < 0 {
+				break
+			}
+			document = document[end+3:]
+			continue
+		}
+		if strings.HasPrefix(document, "<!") || strings.HasPrefix(document, "<?") {
+			end := strings.IndexByte(document, '>')
+			if end < 0 {
+				break
+			}
+			document = document[end+1:]
+			continue
+		}
+
+		name, attributes, closing, end := parseHTMLTag(document)
+		if end < 0 {
+			// Not a tag (such as "a < b")
+			w.text("<")
+			document = document[1:]
+			continue
+		}
+		document = document[end:]
+
+		switch name {
+		case "script", "style", "head", "title":
+			if !closing {
+				if skip := indexFold(document, "</"+name); skip >= 0 {
+					document = document[skip:]
+				} else {
+					document = ""
+				}
+			}
+		case "br":
+			w.lineBreak()
+		case "p", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "table", "ul", "ol", "hr":
+			w.newlines(2)
+		case "div", "tr", "section", "article", "header", "footer", "dl", "dt", "dd", "center":
+			w.newlines(1)
+		case "pre":
+			w.newlines(2)
+			if closing && w.pre > 0 {
+				w.pre--
+			} else if !closing {
+				w.pre++
+			}
+		case "li":
+			w.newlines(1)
+			if !closing {
+				w.text("- ")
+			}
+		case "td", "th":
+			w.space = true
+		case "img":
+			if alt := htmlAttribute(attributes, "alt"); len(alt) > 0 {
+				w.text(" " + alt + " ")
+			}
+		case "a":
+			if !closing {
+				href = htmlAttribute(attributes, "href")
+				linkStart = w.b.Len()
+			} else if linkStart >= 0 {
+				lower := strings.ToLower(href)
+				if (strings.HasPrefix(lower, "http:") || strings.HasPrefix(lower, "https:")) && strings.TrimSpace(w.b.String()[linkStart:]) != href {
+					w.text(" (" + href + ")")
+				}
+				href, linkStart = "", -1
+			}
+		}
+	}
+
+	// Remove trailing spaces from each line
+	lines := strings.Split(w.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// htmlTextWriter writes the text of an HTML document, collapsing whitespace and tracking newlines
+type htmlTextWriter struct {
+	b        strings.Builder
+	trailing int
+	space    bool
+	pre      int
+}
+
+// text writes text, collapsing whitespace unless in pre
+func (w *htmlTextWriter) text(s string) {
+	if w.pre > 0 {
+		w.write(s)
+		return
+	}
+
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if len(s) > 0 {
+			w.space = true
+		}
+		return
+	}
+	if strings.IndexFunc(s, unicode.IsSpace) == 0 {
+		w.space = true
+	}
+
+	for _, word := range words {
+		if w.space && w.trailing == 0 && w.b.Len() > 0 {
+			w.b.WriteByte(' ')
+		}
+		w.write(word)
+		w.space = true
+	}
+	r, _ := utf8.DecodeLastRuneInString(s)
+	w.space = unicode.IsSpace(r)
+}
+
+// write writes s as is, tracking the number of trailing newlines
+func (w *htmlTextWriter) write(s string) {
+	if len(s) == 0 {
+		return
+	}
+	w.b.WriteString(s)
+	trimmed := strings.TrimRight(s, "\n")
+	if len(trimmed) == 0 {
+		w.trailing += len(s)
+	} else {
+		w.trailing = len(s) - len(trimmed)
+	}
+}
+
+// newlines ensures the text ends with at least n newlines (unless nothing has been written yet)
+func (w *htmlTextWriter) newlines(n int) {
+	w.space = false
+	if w.b.Len() == 0 {
+		return
+	}
+	for w.trailing < n {
+		w.b.WriteByte('\n')
+		w.trailing++
+	}
+}
+
+// lineBreak writes a newline
+func (w *htmlTextWriter) lineBreak() {
+	w.space = false
+	w.b.WriteByte('\n')
+	w.trailing++
+}
+
+// parseHTMLTag parses the tag at the start of s, returning its lowercased name, its attributes,
+// whether it is a closing tag, and the index after it (or -1 if s does not start with a tag)
+func parseHTMLTag(s string) (string, string, bool, int) {
+	i := 1
+	closing := i < len(s) && s[i] == '/'
+	if closing {
+		i++
+	}
+	start := i
+	for i < len(s) && (isASCIILetter(s[i]) || (i > start && s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	if i == start {
+		return "", "", false, -1
+	}
+	name := strings.ToLower(s[start:i])
+
+	// Find the end of the tag, skipping quoted attribute values
+	var quote byte
+	for j := i; j < len(s); j++ {
+		switch {
+		case quote != 0:
+			if s[j] == quote {
+				quote = 0
+			}
+		case s[j] == '"' || s[j] == '\'':
+			quote = s[j]
+		case s[j] == '>':
+			return name, s[i:j], closing, j + 1
+		}
+	}
+	return "", "", false, -1
+}
+
+// htmlAttribute returns the unescaped value of the attribute, or an empty string if not found
+func htmlAttribute(attributes string, name string) string {
+	for len(attributes) > 0 {
+		attributes = strings.TrimLeft(attributes, " \t\r\n\f/")
+		end := strings.IndexAny(attributes, "= \t\r\n\f>")
+		if end < 0 {
+			end = len(attributes)
+		}
+		key := attributes[:end]
+		attributes = strings.TrimLeft(attributes[end:], " \t\r\n\f")
+		if len(key) == 0 {
+			return ""
+		}
+
+		var value string
+		if strings.HasPrefix(attributes, "=") {
+			attributes = strings.TrimLeft(attributes[1:], " \t\r\n\f")
+			if len(attributes) > 0 && (attributes[0] == '"' || attributes[0] == '\'') {
+				closing := strings.IndexByte(attributes[1:], attributes[0])
+				if closing < 0 {
+					closing = len(attributes) - 1
+				}
+				value = attributes[1 : closing+1]
+				if closing+2 < len(attributes) {
+					attributes = attributes[closing+2:]
+				} else {
+					attributes = ""
+				}
+			} else {
+				valueEnd := strings.IndexAny(attributes, " \t\r\n\f")
+				if valueEnd < 0 {
+					valueEnd = len(attributes)
+				}
+				value = attributes[:valueEnd]
+				attributes = attributes[valueEnd:]
+			}
+		}
+		if strings.EqualFold(key, name) {
+			return strings.TrimSpace(html.UnescapeString(value))
+		}
+	}
+	return ""
+}
+
+// indexFold returns the index of the first case-insensitive instance of the ASCII substring, or -1
+func indexFold(s string, substring string) int {
+	for i := 0; i+len(substring) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substring)], substring) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isASCIILetter returns true if c is an ASCII letter
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,106 @@
+package ciolite
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// TestDecodedContent tests DecodedContent with various charsets
+func TestDecodedContent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		charset  string
+		content  string
+		expected string
+		err      bool
+	}{
+		{"", "plain", "plain", false},
+		{"UTF-8", "café €", "café €", false},
+		{"ISO-8859-1", "café", "café", false},
+		{"windows-1252", "\u0080 5", "€ 5", false},
+		{"iso-8859-1", "\u0093quoted\u0094 café", "\u201cquoted\u201d café", false},
+		{"windows-1252", "café déjà vu", "café déjà vu", false},
+		{"windows-1251", "café déjà vu", "café déjà vu", false},
+		{"\"koi8-r\"", "café déjà vu", "café déjà vu", false},
+		{"iso-8859-15", "¤", "¤", false},
+		{"shift_jis", "あ already converted", "あ already converted", false},
+		{"shift_jis", "café", "café", false},
+		{"utf-16", "café", "café", false},
+		{"euc-kr", "\u00b0\u00a1", "\u00b0\u00a1", false},
+		{"x-unknown", "unchanged", "unchanged", true},
+	}
+
+	for _, test := range tests {
+		body := GetUserEmailAccountsFolderMessageBodyResponse{Charset: test.charset, Content: test.content}
+		decoded, err := body.DecodedContent()
+		if decoded != test.expected || (err != nil) != test.err {
+			t.Error("Charset: ", test.charset, "; Expected: ", test.expected, "; Got: ", decoded, "; With Error: ", err)
+		}
+	}
+}
+
+// TestHTMLToText tests HTMLToText
+func TestHTMLToText(t *testing.T) {
+	t.Parallel()
+
+	document := `<!DOCTYPE html>
+<html><head><title>Ignored</title><style>p { color: red; }</style></head>
+<body>
+<!-- a comment -->
+<h1>Hello&nbsp;&amp; welcome</h1>
+<p>This   is <b>bold</b>,
+  and this is a <a href="https://example.com/x?a=1&amp;b=2">link</a>.<br>New line &lt;3 &#x1F600;</p>
+<script type="text/javascript">var x = "<p>not text</p>";</script>
+<ul><li>One</li><li>Two <img src="x.png" alt="(image)"></li></ul>
+<p><a href="https://example.com">https://example.com</a> and a < b</p>
+<pre>  keep
+    spacing</pre>
+<table><tr><td>A</td><td>B</td></tr></table>
+</body></html>`
+
+	expected := "Hello & welcome\n\n" +
+		"This is bold, and this is a link (https://example.com/x?a=1&b=2).\nNew line <3 \U0001F600\n\n" +
+		"- One\n- Two (image)\n\n" +
+		"https://example.com and a < b\n\n" +
+		"  keep\n    spacing\n\n" +
+		"A B"
+
+	if text := HTMLToText(document); text != expected {
+		t.Errorf("Expected: %q; Got: %q", expected, text)
+	}
+}
+
+// TestSimulatedMessageBodyText tests MessageBodyText with the bodies from a simulated server
+func TestSimulatedMessageBodyText(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/fakeMessageID/body", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `[
+			{"type": "text/html", "charset": "iso-8859-1", "content": "<p>Café</p>", "body_section": "2"},
+			{"type": "text/plain", "charset": "us-ascii", "content": "", "body_section": "1"}
+		]`)
+		Must(err)
+	})
+
+	bodies, err := cioLite.GetUserEmailAccountsFolderMessageBody("fakeUserID", "fakeLabel", "INBOX", "fakeMessageID", GetUserEmailAccountsFolderMessageBodyParams{})
+	if err != nil {
+		t.Fatal("Expected bodies; Got Error: ", err, "; With Log: ", logger.String())
+	}
+
+	// The empty text/plain body is skipped
+	if best, ok := BestMessageBody(bodies, ""); !ok || best.BodySection != "2" || !best.IsHTML() {
+		t.Error("Expected html body; Got: ", best)
+	}
+	if text, err := MessageBodyText(bodies); err != nil || text != "Café" {
+		t.Error("Expected: Café; Got: ", text, "; With Error: ", err)
+	}
+
+	if _, ok := BestMessageBody(nil, "text/html"); ok {
+		t.Error("Expected no body")
+	}
+}
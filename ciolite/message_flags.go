@@ -0,0 +1,70 @@
+package ciolite
+
+// Message flags shared by: https://context.io/docs/lite/users/email_accounts/folders/messages/flags
+// and: https://context.io/docs/lite/users/email_accounts/folders/messages
+// and: https://context.io/docs/lite/users/webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// MessageFlags data struct within GetUserEmailAccountsFolderMessageFlagsResponse,
+// GetUsersEmailAccountFolderMessagesResponse (with IncludeFlags), and WebhookMessageData.
+// Read is the IMAP \Seen flag, called read by the flags endpoint and seen by webhooks.
+type MessageFlags struct {
+	Read     bool `json:"read,omitempty"`
+	Answered bool `json:"answered,omitempty"`
+	Flagged  bool `json:"flagged,omitempty"`
+	Draft    bool `json:"draft,omitempty"`
+	Deleted  bool `json:"deleted,omitempty"`
+}
+
+// UnmarshalJSON is here because the seen flag is named read or seen, and flags may be a list of IMAP flag names
+func (flags *MessageFlags) UnmarshalJSON(b []byte) error {
+	*flags = MessageFlags{}
+
+	switch {
+	case bytes.Equal([]byte(`null`), b):
+		return nil
+	case len(b) > 0 && b[0] == '[':
+		var names []string
+		if err := json.Unmarshal(b, &names); err != nil {
+			return err
+		}
+		for _, name := range names {
+			switch strings.ToLower(strings.TrimPrefix(name, `\`)) {
+			case "seen", "read":
+				flags.Read = true
+			case "answered":
+				flags.Answered = true
+			case "flagged":
+				flags.Flagged = true
+			case "draft":
+				flags.Draft = true
+			case "deleted":
+				flags.Deleted = true
+			}
+		}
+		return nil
+	}
+
+	type messageFlagsTemp MessageFlags
+	var tmp struct {
+		messageFlagsTemp
+		Seen bool `json:"seen,omitempty"`
+	}
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+	*flags = MessageFlags(tmp.messageFlagsTemp)
+	flags.Read = flags.Read || tmp.Seen
+	return nil
+}
+
+// Flag returns a pointer to set, for the flags of SetUserEmailAccountsFolderMessageFlagsParams
+// (such as Flagged: Flag(true) to set the flag, or Flagged: Flag(false) to clear it).
+func Flag(set bool) *bool {
+	return &set
+}
@@ -0,0 +1,81 @@
+package ciolite
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/contextio/contextio-go/cioutil"
+)
+
+// TestMessageFlagsUnmarshal tests that MessageFlags unmarshals from the flags endpoint, webhooks, and IMAP flag lists
+func TestMessageFlagsUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]MessageFlags{
+		`{"read": true, "flagged": true}`:         {Read: true, Flagged: true},
+		`{"seen": true, "answered": true}`:        {Read: true, Answered: true},
+		`{"seen": false, "draft": true}`:          {Draft: true},
+		`["\\Seen", "\\Deleted", "$Forwarded"]`:   {Read: true, Deleted: true},
+		`null`:                                    {},
+		`{"read": false, "deleted": true}`:        {Deleted: true},
+		`{"flagged": true, "seen": true, "x": 1}`: {Read: true, Flagged: true},
+	}
+
+	for input, expected := range tests {
+		flags := MessageFlags{Answered: true}
+		if err := json.Unmarshal([]byte(input), &flags); err != nil || flags != expected {
+			t.Error("Input: ", input, "; Expected: ", expected, "; Got: ", flags, "; With Error: ", err)
+		}
+	}
+
+	var webhook WebhookCallback
+	Must(json.Unmarshal([]byte(`{"message_data": {"flags": {"seen": true, "flagged": true}}}`), &webhook))
+	if !webhook.MessageData.Flags.Read || !webhook.MessageData.Flags.Flagged {
+		t.Error("Expected read and flagged webhook message; Got: ", webhook.MessageData.Flags)
+	}
+}
+
+// TestSimulatedSetUserEmailAccountsFolderMessageFlagsBulk tests setting flags on one and many messages with a simulated server
+func TestSimulatedSetUserEmailAccountsFolderMessageFlagsBulk(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/", func(w http.ResponseWriter, r *http.Request) {
+		Must(r.ParseForm())
+		if r.Method != "POST" || r.PostForm.Get("flagged") != "1" || r.PostForm.Get("seen") != "0" || len(r.PostForm["draft"]) > 0 {
+			t.Error("Expected flagged=1 and seen=0; Got: ", r.Method, " ", r.PostForm)
+		}
+		if r.URL.Path == "/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/missing/flags" {
+			w.WriteHeader(http.StatusNotFound)
+			_, err := io.WriteString(w, `{"type": "error", "value": "message not found"}`)
+			Must(err)
+			return
+		}
+		_, err := io.WriteString(w, `{"success": true, "flags": {"read": false, "flagged": true}}`)
+		Must(err)
+	})
+
+	params := SetUserEmailAccountsFolderMessageFlagsParams{Flagged: Flag(true), Read: Flag(false)}
+
+	response, err := cioLite.SetUserEmailAccountsFolderMessageFlags("fakeUserID", "fakeLabel", "INBOX", "one", params)
+	if err != nil || !response.Success || response.Flags != (MessageFlags{Flagged: true}) {
+		t.Error("Expected flagged message; Got: ", response, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+
+	results, err := cioLite.SetUserEmailAccountsFolderMessageFlagsBulk(context.Background(), "fakeUserID", "fakeLabel", "INBOX", []string{"one", "missing", "two"}, params)
+	if err == nil || len(results) != 3 || results[0].Err != nil || results[1].Err == nil || results[2].Err != nil || !results[2].Flags.Flagged {
+		t.Error("Expected one of three messages to fail; Got: ", results, "; With Error: ", err)
+	}
+
+	// At least one flag is required
+	if _, err := cioLite.SetUserEmailAccountsFolderMessageFlagsBulk(context.Background(), "fakeUserID", "fakeLabel", "INBOX", []string{"one"}, SetUserEmailAccountsFolderMessageFlagsParams{}); err == nil {
+		t.Error("Expected validation error")
+	} else if _, ok := cioutil.AsValidationError(err); !ok {
+		t.Error("Expected validation error; Got: ", err)
+	}
+}
@@ -0,0 +1,442 @@
+package ciolite
+
+// Typed header access that supports: https://context.io/docs/lite/users/email_accounts/folders/messages/headers
+// and the IncludeHeaders option of: https://context.io/docs/lite/users/email_accounts/folders/messages
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MessageHeaders are the headers of a message, by name. Names are matched case-insensitively,
+// as CIO does not canonicalize them. Unmarshals from both the JSON (an object) and the raw (a string) header modes.
+type MessageHeaders map[string][]string
+
+// UnmarshalJSON is here because the headers are an object when requested as JSON, and a string when requested raw.
+// Names are canonicalized, and malformed raw header lines are skipped rather than failing the whole response.
+func (headers *MessageHeaders) UnmarshalJSON(b []byte) error {
+	switch {
+	case bytes.Equal([]byte(`[]`), b), bytes.Equal([]byte(`null`), b):
+		*headers = make(MessageHeaders)
+		return nil
+	case len(b) > 0 && b[0] == '"':
+		var raw string
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return err
+		}
+		// Any error is for malformed lines, which ParseRawHeaders skips
+		*headers, _ = ParseRawHeaders(raw)
+		return nil
+	}
+
+	mp := make(map[string][]string)
+	if err := json.Unmarshal(b, &mp); err != nil {
+		return err
+	}
+
+	// Merge names with different casings, in a deterministic order (the order between them is not known)
+	keys := make([]string, 0, len(mp))
+	for key := range mp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	*headers = make(MessageHeaders, len(mp))
+	for _, key := range keys {
+		canonical := textproto.CanonicalMIMEHeaderKey(key)
+		(*headers)[canonical] = append((*headers)[canonical], mp[key]...)
+	}
+	return nil
+}
+
+// ParseRawHeaders parses raw (RFC 5322) headers, such as from the raw header mode or a raw message,
+// unfolding them and stopping at the first empty line. Lines that are not headers (such as an mbox "From " line)
+// are skipped, returning the other headers along with an error.
+func ParseRawHeaders(raw string) (MessageHeaders, error) {
+	headers := make(MessageHeaders)
+	raw = strings.TrimLeft(strings.Replace(raw, "\r\n", "\n", -1), "\n")
+
+	var name string
+	var malformed []string
+	for _, line := range strings.Split(raw, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			if len(line) == 0 {
+				break
+			}
+			continue
+		}
+
+		// Continuation of a folded header
+		if line[0] == ' ' || line[0] == '\t' {
+			if values := headers[name]; len(values) > 0 {
+				values[len(values)-1] += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 || len(strings.TrimRight(line[:colon], " \t")) == 0 || strings.ContainsAny(strings.TrimRight(line[:colon], " \t"), " \t") {
+			name = ""
+			malformed = append(malformed, line)
+			continue
+		}
+		name = textproto.CanonicalMIMEHeaderKey(strings.TrimRight(line[:colon], " \t"))
+		headers[name] = append(headers[name], strings.TrimSpace(line[colon+1:]))
+	}
+
+	if len(malformed) > 0 {
+		return headers, errors.Errorf("CIO: Skipped %d malformed raw header lines, the first: %q", len(malformed), malformed[0])
+	}
+	return headers, nil
+}
+
+// Values returns all values of the header, in the order they appear in the message
+func (headers MessageHeaders) Values(name string) []string {
+	if values, ok := headers[textproto.CanonicalMIMEHeaderKey(name)]; ok && headers.keyCount(name) == 1 {
+		return values
+	}
+	return headerValues(headers, name)
+}
+
+// Get returns the first value of the header, or an empty string if not present
+func (headers MessageHeaders) Get(name string) string {
+	if values := headers.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Has returns true if the message has the header
+func (headers MessageHeaders) Has(name string) bool {
+	return len(headers.Values(name)) > 0
+}
+
+// Decoded returns the first value of the header, unfolded and with any RFC 2047 encoded-words decoded
+func (headers MessageHeaders) Decoded(name string) string {
+	return DecodeHeader(headers.Get(name))
+}
+
+// Date returns the parsed Date header
+func (headers MessageHeaders) Date() (time.Time, error) {
+	date := unfoldHeader(headers.Get("Date"))
+	if len(date) == 0 {
+		return time.Time{}, errors.New("CIO: Message has no Date header")
+	}
+	parsed, err := mail.ParseDate(stripHeaderComments(date))
+	return parsed, errors.Wrap(err, "CIO: Unable to parse Date header")
+}
+
+// AddressList returns the parsed addresses of all values of an address header (such as From, To, Cc, or Reply-To),
+// with names decoded. Values that fail to parse are skipped, returning the first error.
+func (headers MessageHeaders) AddressList(name string) ([]*mail.Address, error) {
+	parser := mail.AddressParser{WordDecoder: headerWordDecoder()}
+
+	var addresses []*mail.Address
+	var firstErr error
+	for _, value := range headers.Values(name) {
+		if value = unfoldHeader(value); len(value) == 0 {
+			continue
+		}
+		parsed, err := parser.ParseList(value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrap(err, "CIO: Unable to parse "+name+" header")
+			}
+			continue
+		}
+		addresses = append(addresses, parsed...)
+	}
+	return addresses, firstErr
+}
+
+// Received returns the parsed Received headers, most recent (top-most) first
+func (headers MessageHeaders) Received() []ReceivedHeader {
+	values := headers.Values("Received")
+	received := make([]ReceivedHeader, 0, len(values))
+	for _, value := range values {
+		received = append(received, ParseReceivedHeader(value))
+	}
+	return received
+}
+
+// AuthenticationResults returns the parsed Authentication-Results headers, most recent (top-most) first
+func (headers MessageHeaders) AuthenticationResults() []AuthenticationResults {
+	values := headers.Values("Authentication-Results")
+	results := make([]AuthenticationResults, 0, len(values))
+	for _, value := range values {
+		results = append(results, ParseAuthenticationResults(value))
+	}
+	return results
+}
+
+// keyCount returns the number of casings the header is present under
+func (headers MessageHeaders) keyCount(name string) int {
+	count := 0
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			count++
+		}
+	}
+	return count
+}
+
+// DecodeHeader unfolds a header value and decodes any RFC 2047 encoded-words, in any known charset.
+// The value is returned unfolded but otherwise unchanged if it can not be decoded.
+func DecodeHeader(value string) string {
+	value = unfoldHeader(value)
+	decoded, err := headerWordDecoder().DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// headerWordDecoder returns an RFC 2047 decoder supporting all charsets known to charsetEncoding
+func headerWordDecoder() *mime.WordDecoder {
+	return &mime.WordDecoder{
+		CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+			enc, err := charsetEncoding(strings.ToLower(charset))
+			if err != nil {
+				return nil, err
+			}
+			return enc.NewDecoder().Reader(input), nil
+		},
+	}
+}
+
+// ReceivedHeader is a parsed Received header: the clauses (without comments),
+// the IP address of the sending host (from the from clause comment), and the date.
+type ReceivedHeader struct {
+	From   string
+	FromIP string
+	By     string
+	Via    string
+	With   string
+	ID     string
+	For    string
+	Date   time.Time
+	Raw    string
+}
+
+// ParseReceivedHeader parses a Received header value, such as:
+//
+//	from mail.example.com (mail.example.com [192.0.2.1]) by mx.example.org with ESMTPS id abc123 for <me@example.org>; Tue, 1 May 2018 10:00:00 -0700
+//
+// Clauses that are missing or malformed are left empty.
+func ParseReceivedHeader(value string) ReceivedHeader {
+	received := ReceivedHeader{Raw: value}
+	value = unfoldHeader(value)
+
+	clauses := value
+	if semicolon := strings.LastIndex(value, ";"); semicolon >= 0 {
+		clauses = value[:semicolon]
+		if date, err := mail.ParseDate(stripHeaderComments(strings.TrimSpace(value[semicolon+1:]))); err == nil {
+			received.Date = date
+		}
+	}
+
+	keywords := map[string]*string{
+		"from": &received.From, "by": &received.By, "via": &received.Via,
+		"with": &received.With, "id": &received.ID, "for": &received.For,
+	}
+
+	var clause *string
+	fromClause := false
+	for _, token := range headerTokens(clauses) {
+		if strings.HasPrefix(token, "(") {
+			if fromClause && len(received.FromIP) == 0 {
+				received.FromIP = bracketedIP(token)
+			}
+			continue
+		}
+
+		if next, ok := keywords[strings.ToLower(token)]; ok {
+			clause, fromClause = next, next == &received.From
+			continue
+		}
+		if clause != nil && len(*clause) == 0 {
+			*clause = strings.Trim(token, "<>")
+			if fromClause && len(received.FromIP) == 0 {
+				received.FromIP = bracketedIP(token)
+			}
+		}
+	}
+	return received
+}
+
+// AuthenticationResults is a parsed Authentication-Results header (RFC 8601)
+type AuthenticationResults struct {
+	AuthServID string
+	Results    []AuthenticationResult
+	Raw        string
+}
+
+// AuthenticationResult is the result of one authentication method (such as spf, dkim, or dmarc),
+// with its lowercased Method and Result, and its properties (such as "smtp.mailfrom" or "header.d") by name.
+type AuthenticationResult struct {
+	Method     string
+	Result     string
+	Reason     string
+	Properties map[string]string
+}
+
+// Result returns the first result of the method (such as "dkim"), and false if there is none
+func (results AuthenticationResults) Result(method string) (AuthenticationResult, bool) {
+	for _, result := range results.Results {
+		if strings.EqualFold(result.Method, method) {
+			return result, true
+		}
+	}
+	return AuthenticationResult{}, false
+}
+
+// ParseAuthenticationResults parses an Authentication-Results header value, such as:
+//
+//	mx.example.org; spf=pass smtp.mailfrom=example.com; dkim=pass header.d=example.com
+func ParseAuthenticationResults(value string) AuthenticationResults {
+	results := AuthenticationResults{Raw: value}
+
+	parts := splitQuoted(stripHeaderComments(unfoldHeader(value)), ';')
+	if len(parts) == 0 {
+		return results
+	}
+	if fields := strings.Fields(parts[0]); len(fields) > 0 {
+		results.AuthServID = fields[0]
+	}
+
+	for _, part := range parts[1:] {
+		var result AuthenticationResult
+		for i, token := range splitQuoted(part, ' ', '\t') {
+			key, val := upToSeparator(token, "="), ""
+			if len(key) < len(token) {
+				val = strings.Trim(token[len(key)+1:], `"`)
+			}
+			key = strings.ToLower(key)
+
+			switch {
+			case i == 0:
+				result.Method = upToSeparator(key, "/")
+				result.Result = strings.ToLower(val)
+			case key == "reason":
+				result.Reason = val
+			case len(val) > 0:
+				if result.Properties == nil {
+					result.Properties = make(map[string]string)
+				}
+				result.Properties[key] = val
+			}
+		}
+		if len(result.Method) > 0 && result.Method != "none" {
+			results.Results = append(results.Results, result)
+		}
+	}
+	return results
+}
+
+// headerTokens splits a header value into words and (possibly nested) comments
+func headerTokens(value string) []string {
+	var tokens []string
+	for i := 0; i < len(value); {
+		switch c := value[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			end := commentEnd(value, i)
+			tokens = append(tokens, value[i:end])
+			i = end
+		default:
+			end := strings.IndexAny(value[i:], " \t(")
+			if end < 0 {
+				end = len(value) - i
+			}
+			tokens = append(tokens, value[i:i+end])
+			i += end
+		}
+	}
+	return tokens
+}
+
+// stripHeaderComments removes (possibly nested) comments from a header value, outside of quoted strings
+func stripHeaderComments(value string) string {
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value):
+			b.WriteString(value[i : i+2])
+			i++
+		case c == '"':
+			quoted = !quoted
+			b.WriteByte(c)
+		case c == '(' && !quoted:
+			i = commentEnd(value, i) - 1
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// commentEnd returns the index after the comment starting at start
+func commentEnd(value string, start int) int {
+	depth := 0
+	for i := start; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(value)
+}
+
+// splitQuoted splits the value at any of the separators outside of quoted strings, dropping empty parts
+func splitQuoted(value string, separators ...byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) {
+			c := value[i]
+			if c == '"' {
+				quoted = !quoted
+			}
+			if quoted || bytes.IndexByte(separators, c) < 0 {
+				continue
+			}
+		}
+		if part := strings.TrimSpace(value[start:i]); len(part) > 0 {
+			parts = append(parts, part)
+		}
+		start = i + 1
+	}
+	return parts
+}
+
+// bracketedIP returns the IP address within square brackets (such as "[192.0.2.1]" or "[IPv6:2001:db8::1]")
+func bracketedIP(value string) string {
+	open := strings.Index(value, "[")
+	closing := strings.Index(value, "]")
+	if open < 0 || closing < open {
+		return ""
+	}
+	ip := value[open+1 : closing]
+	if strings.HasPrefix(strings.ToLower(ip), "ipv6:") {
+		ip = ip[5:]
+	}
+	return ip
+}
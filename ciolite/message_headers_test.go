@@ -0,0 +1,129 @@
+package ciolite
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSimulatedMessageHeaders tests MessageHeaders with the JSON and raw header modes from a simulated server
+func TestSimulatedMessageHeaders(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages/fakeMessageID/headers", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("raw") == "1" {
+			_, err := io.WriteString(w, `{"headers": "Received: from mail.example.com (mail.example.com [192.0.2.1])\r\n\tby mx.example.org with ESMTPS id abc123\r\n\tfor <me@example.org>; Tue, 1 May 2018 10:00:00 -0700 (PDT)\r\nReceived: by 10.0.0.1 with SMTP id xyz; Tue, 1 May 2018 09:59:58 -0700\r\nSubject: =?ISO-8859-1?Q?Caf=E9?= news\r\nFrom: =?UTF-8?B?SsO8cmdlbg==?= <jurgen@example.com>\r\nTo: a@example.org, \"B, Person\" <b@example.org>\r\nCc: c@example.org\r\nDate: Tue, 1 May 2018 16:59:50 +0000 (UTC)\r\n\r\n"}`)
+			Must(err)
+			return
+		}
+		_, err := io.WriteString(w, `{"headers": {"subject": ["Hello"], "cc": ["c@example.org"], "CC": ["d@example.org"], "received": ["from a by b; Tue, 1 May 2018 10:00:00 -0700"]}}`)
+		Must(err)
+	})
+
+	// Raw mode
+	raw, err := cioLite.GetUserEmailAccountsFolderMessageHeaders("fakeUserID", "fakeLabel", "INBOX", "fakeMessageID", GetUserEmailAccountsFolderMessageHeadersParams{Raw: true})
+	if err != nil {
+		t.Fatal("Expected raw headers; Got Error: ", err, "; With Log: ", logger.String())
+	}
+	headers := raw.Headers
+
+	if subject := headers.Decoded("subject"); subject != "Café news" {
+		t.Error("Expected: Café news; Got: ", subject)
+	}
+	if date, err := headers.Date(); err != nil || !date.Equal(time.Date(2018, 5, 1, 16, 59, 50, 0, time.UTC)) {
+		t.Error("Expected date; Got: ", date, "; With Error: ", err)
+	}
+
+	from, err := headers.AddressList("FROM")
+	if err != nil || len(from) != 1 || from[0].Name != "Jürgen" || from[0].Address != "jurgen@example.com" {
+		t.Error("Expected Jürgen; Got: ", from, "; With Error: ", err)
+	}
+	to, err := headers.AddressList("To")
+	if err != nil || len(to) != 2 || to[1].Name != "B, Person" || to[1].Address != "b@example.org" {
+		t.Error("Expected 2 To addresses; Got: ", to, "; With Error: ", err)
+	}
+
+	received := headers.Received()
+	expected := ReceivedHeader{
+		From:   "mail.example.com",
+		FromIP: "192.0.2.1",
+		By:     "mx.example.org",
+		With:   "ESMTPS",
+		ID:     "abc123",
+		For:    "me@example.org",
+		Date:   time.Date(2018, 5, 1, 17, 0, 0, 0, time.UTC),
+	}
+	if len(received) != 2 || received[1].By != "10.0.0.1" || received[1].ID != "xyz" {
+		t.Fatal("Expected 2 Received headers; Got: ", received)
+	}
+	received[0].Raw, received[0].Date = "", received[0].Date.UTC()
+	if !reflect.DeepEqual(received[0], expected) {
+		t.Error("Expected: ", expected, "; Got: ", received[0])
+	}
+
+	// JSON mode, with inconsistent casing
+	parsed, err := cioLite.GetUserEmailAccountsFolderMessageHeaders("fakeUserID", "fakeLabel", "INBOX", "fakeMessageID", GetUserEmailAccountsFolderMessageHeadersParams{})
+	if err != nil || parsed.Headers.Get("Subject") != "Hello" || !parsed.Headers.Has("RECEIVED") || parsed.Headers.Has("Date") {
+		t.Error("Expected JSON headers; Got: ", parsed, "; With Error: ", err)
+	}
+	if cc, err := parsed.Headers.AddressList("Cc"); err != nil || len(cc) != 2 || cc[0].Address != "d@example.org" {
+		t.Error("Expected 2 Cc addresses, merged in order; Got: ", cc, "; With Error: ", err)
+	}
+	if _, err := parsed.Headers.Date(); err == nil {
+		t.Error("Expected error for missing Date header")
+	}
+}
+
+// TestParseRawHeadersMalformed tests that malformed raw header lines are skipped, keeping the other headers
+func TestParseRawHeadersMalformed(t *testing.T) {
+	t.Parallel()
+
+	raw := "From sender@example.com Tue May  1 10:00:00 2018\r\nSubject: Hello\r\n\tworld\r\nnot a header\r\nFrom: a@example.com\r\n\r\nBody: text"
+
+	headers, err := ParseRawHeaders(raw)
+	if err == nil || !reflect.DeepEqual(headers, MessageHeaders{"Subject": {"Hello world"}, "From": {"a@example.com"}}) {
+		t.Error("Expected Subject and From with an error; Got: ", headers, "; With Error: ", err)
+	}
+
+	// Unmarshaling does not fail the response
+	var response GetUserEmailAccountsFolderMessageHeadersResponse
+	if err = json.Unmarshal([]byte(`{"headers": "From sender@example.com Tue May  1 10:00:00 2018\nSubject: Hello\n"}`), &response); err != nil || response.Headers.Get("subject") != "Hello" {
+		t.Error("Expected Subject; Got: ", response.Headers, "; With Error: ", err)
+	}
+}
+
+// TestParseAuthenticationResults tests ParseAuthenticationResults
+func TestParseAuthenticationResults(t *testing.T) {
+	t.Parallel()
+
+	results := ParseAuthenticationResults("mx.google.com;\r\n       dkim=pass header.i=@example.com header.s=s1 header.b=\"abc;def\";\r\n" +
+		"       spf=pass (google.com: domain of bounce@example.com designates 192.0.2.1 as permitted sender) smtp.mailfrom=bounce@example.com;\r\n" +
+		"       dmarc=FAIL reason=\"policy (p=reject)\" (p=REJECT sp=REJECT dis=NONE) header.from=example.com")
+
+	if results.AuthServID != "mx.google.com" || len(results.Results) != 3 {
+		t.Fatal("Expected 3 results from mx.google.com; Got: ", results)
+	}
+
+	dkim, ok := results.Result("DKIM")
+	if !ok || dkim.Result != "pass" || dkim.Properties["header.i"] != "@example.com" || dkim.Properties["header.b"] != "abc;def" {
+		t.Error("Unexpected dkim result: ", dkim)
+	}
+	spf, ok := results.Result("spf")
+	if !ok || spf.Result != "pass" || spf.Properties["smtp.mailfrom"] != "bounce@example.com" {
+		t.Error("Unexpected spf result: ", spf)
+	}
+	dmarc, ok := results.Result("dmarc")
+	if !ok || dmarc.Result != "fail" || dmarc.Reason != "policy (p=reject)" || dmarc.Properties["header.from"] != "example.com" {
+		t.Error("Unexpected dmarc result: ", dmarc)
+	}
+
+	if none := ParseAuthenticationResults("example.org 1; none"); none.AuthServID != "example.org" || len(none.Results) != 0 {
+		t.Error("Expected no results; Got: ", none)
+	}
+}
@@ -0,0 +1,345 @@
+package ciolite
+
+// Credential rotation that supports: https://context.io/docs/lite/oauth_providers
+// and: https://context.io/docs/lite/users/email_accounts#id-post
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OAuthProviderServers maps the Type of an OAuth provider to the IMAP servers of the email accounts it connects,
+// for use with OAuthAccountsOnServers.
+var OAuthProviderServers = map[string][]string{
+	"GMAIL_OAUTH2":         {"imap.gmail.com", "imap.googlemail.com"},
+	"MSLIVECONNECT_OAUTH2": {"imap-mail.outlook.com", "outlook.office365.com"},
+}
+
+// OAuthAccountsOnServers returns an OAuthProviderRotation Affects func matching every OAuth email account on the servers
+// (such as OAuthProviderServers["GMAIL_OAUTH2"]), whatever OAuth provider it uses.
+// This is only correct if there is a single OAuth provider for the servers.
+func OAuthAccountsOnServers(servers []string) func(user GetUsersResponse, emailAccount GetUsersEmailAccountsResponse) bool {
+	return func(user GetUsersResponse, emailAccount GetUsersEmailAccountsResponse) bool {
+		return strings.Contains(strings.ToLower(emailAccount.AuthenticationType), "oauth") &&
+			containsFoldString(servers, emailAccount.Server)
+	}
+}
+
+// RotationPhase is the phase an OAuthProviderRotation has reached
+type RotationPhase string
+
+const (
+	// RotationPending means the rotation has not started
+	RotationPending RotationPhase = "pending"
+
+	// RotationProviderCreated means the new OAuth provider has been created
+	RotationProviderCreated RotationPhase = "provider_created"
+
+	// RotationAccountsListed means the affected email accounts have been listed, and are being updated
+	RotationAccountsListed RotationPhase = "accounts_listed"
+
+	// RotationCompleted means all affected email accounts were updated, and the old OAuth provider was deleted
+	RotationCompleted RotationPhase = "completed"
+
+	// RotationRolledBack means the updated email accounts were reverted, and the new OAuth provider was deleted
+	RotationRolledBack RotationPhase = "rolled_back"
+)
+
+// RotatedAccountStatus is the status of a single email account in an OAuthProviderRotation
+type RotatedAccountStatus string
+
+const (
+	// RotatedAccountPending means the email account has not been updated yet
+	RotatedAccountPending RotatedAccountStatus = "pending"
+
+	// RotatedAccountUpdated means the email account was updated to the new OAuth provider
+	RotatedAccountUpdated RotatedAccountStatus = "updated"
+
+	// RotatedAccountFailed means updating the email account failed, and will be retried when resumed
+	RotatedAccountFailed RotatedAccountStatus = "failed"
+
+	// RotatedAccountRolledBack means the email account was reverted to the old OAuth provider
+	RotatedAccountRolledBack RotatedAccountStatus = "rolled_back"
+)
+
+// RotatedAccount is the result of rotating a single email account
+type RotatedAccount struct {
+	UserID   string               `json:"user_id"`
+	Label    string               `json:"label"`
+	Username string               `json:"username,omitempty"`
+	Status   RotatedAccountStatus `json:"status"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// OAuthProviderRotationState is the progress of an OAuthProviderRotation.
+// It can be marshaled to json and persisted (with OnProgress), then given back to resume the rotation.
+type OAuthProviderRotationState struct {
+	OldProviderConsumerKey string           `json:"old_provider_consumer_key"`
+	NewProviderConsumerKey string           `json:"new_provider_consumer_key,omitempty"`
+	Phase                  RotationPhase    `json:"phase"`
+	Accounts               []RotatedAccount `json:"accounts,omitempty"`
+}
+
+// Progress returns the number of email accounts updated (or rolled back), and the total number of email accounts
+func (state OAuthProviderRotationState) Progress() (done int, total int) {
+	for _, account := range state.Accounts {
+		if account.Status == RotatedAccountUpdated || account.Status == RotatedAccountRolledBack {
+			done++
+		}
+	}
+	return done, len(state.Accounts)
+}
+
+// Failed returns the email accounts that failed to update
+func (state OAuthProviderRotationState) Failed() []RotatedAccount {
+	var failed []RotatedAccount
+	for _, account := range state.Accounts {
+		if account.Status == RotatedAccountFailed {
+			failed = append(failed, account)
+		}
+	}
+	return failed
+}
+
+// OAuthProviderRotation rotates the credentials of an OAuth provider: it creates the New OAuth provider,
+// updates every affected email account to the new ProviderConsumerKey, then deletes the old OAuth provider.
+// Progress is tracked in State, so that a failed or interrupted rotation can be resumed or rolled back.
+type OAuthProviderRotation struct {
+	CioLite CioLite
+
+	// Required: the consumer key of the OAuth provider being replaced, and the new OAuth provider
+	OldProviderConsumerKey string
+	New                    CreateOAuthProviderParams
+
+	// Optional: the state of a previous run to resume (defaults to starting a new rotation)
+	State *OAuthProviderRotationState
+
+	// Optional: invoked with a copy of the State after each step, to persist it
+	OnProgress func(state OAuthProviderRotationState)
+
+	// Required (unless resuming after the email accounts are listed): returns true if the email account uses the old OAuth provider.
+	// CIO does not return the ProviderConsumerKey of email accounts, so with more than one OAuth provider of the same Type
+	// this must tell their email accounts apart (such as by user), rather than use OAuthAccountsOnServers.
+	Affects func(user GetUsersResponse, emailAccount GetUsersEmailAccountsResponse) bool
+
+	// Optional: do not delete the old OAuth provider once all email accounts are updated
+	KeepOldProvider bool
+}
+
+// Run runs (or resumes) the rotation, returning the State.
+// If any email account fails to update, the old OAuth provider is kept and an error is returned:
+// Run again to retry the failed email accounts, or Rollback.
+func (rotation *OAuthProviderRotation) Run(ctx context.Context) (OAuthProviderRotationState, error) {
+	cioLite := rotation.CioLite.WithContext(ctx)
+
+	if rotation.State == nil {
+		rotation.State = &OAuthProviderRotationState{OldProviderConsumerKey: rotation.OldProviderConsumerKey, Phase: RotationPending}
+	}
+	state := rotation.State
+
+	if rotation.Affects == nil && (state.Phase == RotationPending || state.Phase == RotationProviderCreated) {
+		return *state, errors.New("CIO: OAuth provider rotation requires Affects to find the email accounts of the old provider")
+	}
+
+	if state.Phase == RotationCompleted || state.Phase == RotationRolledBack {
+		return *state, errors.Errorf("CIO: OAuth provider rotation already %s", state.Phase)
+	}
+
+	// Create the new provider (unless it already exists, such as when interrupted before persisting the state)
+	if state.Phase == RotationPending {
+		oldProvider, newProvider, err := rotation.providers(cioLite)
+		if err != nil {
+			return *state, err
+		}
+		if len(oldProvider.ProviderConsumerKey) == 0 {
+			return *state, errors.Errorf("CIO: OAuth provider %s not found", state.OldProviderConsumerKey)
+		}
+		if !strings.EqualFold(oldProvider.Type, rotation.New.Type) {
+			return *state, errors.Errorf("CIO: OAuth provider type %s does not match %s", rotation.New.Type, oldProvider.Type)
+		}
+
+		state.NewProviderConsumerKey = newProvider.ProviderConsumerKey
+		if len(state.NewProviderConsumerKey) == 0 {
+			created, err := cioLite.CreateOAuthProvider(rotation.New)
+			if err != nil {
+				return *state, errors.Wrap(err, "CIO: Unable to create OAuth provider")
+			}
+			if !created.Success {
+				return *state, errors.New("CIO: Unable to create OAuth provider")
+			}
+			state.NewProviderConsumerKey = created.ProviderConsumerKey
+			if len(state.NewProviderConsumerKey) == 0 {
+				state.NewProviderConsumerKey = rotation.New.ProviderConsumerKey
+			}
+		}
+		state.Phase = RotationProviderCreated
+		rotation.progress()
+	}
+
+	// List the affected email accounts
+	if state.Phase == RotationProviderCreated {
+		accounts, err := rotation.affectedAccounts(cioLite)
+		if err != nil {
+			return *state, err
+		}
+		state.Accounts = accounts
+		state.Phase = RotationAccountsListed
+		rotation.progress()
+	}
+
+	// Update each email account not yet updated
+	for i := range state.Accounts {
+		account := &state.Accounts[i]
+		if account.Status == RotatedAccountUpdated {
+			continue
+		}
+		if ctx.Err() != nil {
+			return *state, errors.Wrap(ctx.Err(), "CIO: OAuth provider rotation interrupted")
+		}
+		account.Status, account.Error = rotation.modify(cioLite, *account, state.NewProviderConsumerKey, RotatedAccountUpdated)
+		rotation.progress()
+	}
+
+	if failed := state.Failed(); len(failed) > 0 {
+		return *state, errors.Errorf("CIO: Unable to update %d email accounts to OAuth provider %s", len(failed), state.NewProviderConsumerKey)
+	}
+
+	// Delete the old provider
+	if !rotation.KeepOldProvider {
+		if err := rotation.deleteProvider(cioLite, state.OldProviderConsumerKey); err != nil {
+			return *state, err
+		}
+	}
+	state.Phase = RotationCompleted
+	rotation.progress()
+
+	return *state, nil
+}
+
+// Rollback reverts the email accounts that were updated back to the old OAuth provider,
+// then deletes the new OAuth provider. A completed rotation can not be rolled back,
+// since the old OAuth provider has been deleted.
+func (rotation *OAuthProviderRotation) Rollback(ctx context.Context) (OAuthProviderRotationState, error) {
+	cioLite := rotation.CioLite.WithContext(ctx)
+
+	state := rotation.State
+	if state == nil || state.Phase == RotationPending {
+		return OAuthProviderRotationState{Phase: RotationRolledBack}, nil
+	}
+	if state.Phase == RotationCompleted && !rotation.KeepOldProvider {
+		return *state, errors.New("CIO: Completed OAuth provider rotation can not be rolled back")
+	}
+
+	var failed int
+	for i := range state.Accounts {
+		account := &state.Accounts[i]
+		if account.Status != RotatedAccountUpdated {
+			continue
+		}
+		account.Status, account.Error = rotation.modify(cioLite, *account, state.OldProviderConsumerKey, RotatedAccountRolledBack)
+		if account.Status != RotatedAccountRolledBack {
+			account.Status = RotatedAccountUpdated
+			failed++
+		}
+		rotation.progress()
+	}
+	if failed > 0 {
+		return *state, errors.Errorf("CIO: Unable to roll back %d email accounts to OAuth provider %s", failed, state.OldProviderConsumerKey)
+	}
+
+	if len(state.NewProviderConsumerKey) > 0 && state.NewProviderConsumerKey != state.OldProviderConsumerKey {
+		if err := rotation.deleteProvider(cioLite, state.NewProviderConsumerKey); err != nil {
+			return *state, err
+		}
+	}
+	state.Phase = RotationRolledBack
+	rotation.progress()
+
+	return *state, nil
+}
+
+// providers returns the old and new OAuth providers, if they exist
+func (rotation *OAuthProviderRotation) providers(cioLite CioLite) (GetOAuthProvidersResponse, GetOAuthProvidersResponse, error) {
+	var oldProvider, newProvider GetOAuthProvidersResponse
+	providers, err := cioLite.GetOAuthProviders()
+	if err != nil {
+		return oldProvider, newProvider, errors.Wrap(err, "CIO: Unable to list OAuth providers")
+	}
+	for _, provider := range providers {
+		switch provider.ProviderConsumerKey {
+		case rotation.State.OldProviderConsumerKey:
+			oldProvider = provider
+		case rotation.New.ProviderConsumerKey:
+			newProvider = provider
+		}
+	}
+	return oldProvider, newProvider, nil
+}
+
+// affectedAccounts lists all users, returning the email accounts affected by the rotation
+func (rotation *OAuthProviderRotation) affectedAccounts(cioLite CioLite) ([]RotatedAccount, error) {
+	users, err := cioLite.GetAllUsers(GetUsersParams{})
+	if err != nil {
+		return nil, errors.Wrap(err, "CIO: Unable to list users")
+	}
+
+	accounts := []RotatedAccount{}
+	for _, user := range users {
+		for _, emailAccount := range user.EmailAccounts {
+			if rotation.Affects(user, emailAccount) {
+				accounts = append(accounts, RotatedAccount{
+					UserID:   user.ID,
+					Label:    emailAccount.Label,
+					Username: emailAccount.Username,
+					Status:   RotatedAccountPending,
+				})
+			}
+		}
+	}
+	return accounts, nil
+}
+
+// modify sets the ProviderConsumerKey of the email account, returning the status (or failed) and any error
+func (rotation *OAuthProviderRotation) modify(cioLite CioLite, account RotatedAccount, providerConsumerKey string, status RotatedAccountStatus) (RotatedAccountStatus, string) {
+	response, err := cioLite.ModifyUserEmailAccount(account.UserID, account.Label, ModifyUserEmailAccountParams{ProviderConsumerKey: providerConsumerKey})
+	if err != nil {
+		return RotatedAccountFailed, err.Error()
+	}
+	if !response.Success {
+		return RotatedAccountFailed, "CIO: Unable to modify email account: " + response.FeedbackCode
+	}
+	return status, ""
+}
+
+// deleteProvider deletes the OAuth provider
+func (rotation *OAuthProviderRotation) deleteProvider(cioLite CioLite, providerConsumerKey string) error {
+	response, err := cioLite.DeleteOAuthProvider(providerConsumerKey)
+	if err != nil {
+		return errors.Wrapf(err, "CIO: Unable to delete OAuth provider %s", providerConsumerKey)
+	}
+	if !response.Success {
+		return errors.Errorf("CIO: Unable to delete OAuth provider %s", providerConsumerKey)
+	}
+	return nil
+}
+
+// progress invokes OnProgress with a copy of the State
+func (rotation *OAuthProviderRotation) progress() {
+	if rotation.OnProgress != nil {
+		state := *rotation.State
+		state.Accounts = append([]RotatedAccount(nil), state.Accounts...)
+		rotation.OnProgress(state)
+	}
+}
+
+// containsFoldString returns true if the slice contains the string, ignoring case
+func containsFoldString(slice []string, s string) bool {
+	for _, v := range slice {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package ciolite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// rotationTestServer simulates the OAuth providers, users, and email accounts of a rotation
+type rotationTestServer struct {
+	mu        sync.Mutex
+	providers map[string]string
+	keys      map[string]string
+	failing   map[string]bool
+}
+
+// newRotationTestServer sets up the handlers for a rotation on the mux
+func newRotationTestServer(mux *http.ServeMux) *rotationTestServer {
+	server := &rotationTestServer{
+		providers: map[string]string{"oldkey": "GMAIL_OAUTH2", "mskey": "MSLIVECONNECT_OAUTH2"},
+		keys:      map[string]string{"user1/a": "oldkey", "user2/b": "oldkey"},
+		failing:   map[string]bool{"user2/b": true},
+	}
+
+	mux.HandleFunc("/oauth_providers", func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		if r.Method == "POST" {
+			Must(r.ParseForm())
+			server.providers[r.PostForm.Get("provider_consumer_key")] = r.PostForm.Get("type")
+			Must(json.NewEncoder(w).Encode(CreateOAuthProviderResponse{Success: true, ProviderConsumerKey: r.PostForm.Get("provider_consumer_key")}))
+			return
+		}
+		var providers []GetOAuthProvidersResponse
+		for key, providerType := range server.providers {
+			providers = append(providers, GetOAuthProvidersResponse{Type: providerType, ProviderConsumerKey: key})
+		}
+		Must(json.NewEncoder(w).Encode(providers))
+	})
+	mux.HandleFunc("/oauth_providers/", func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		delete(server.providers, r.URL.Path[len("/oauth_providers/"):])
+		Must(json.NewEncoder(w).Encode(DeleteOAuthProviderResponse{Success: true}))
+	})
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		Must(json.NewEncoder(w).Encode([]GetUsersResponse{
+			{ID: "user1", EmailAccounts: []GetUsersEmailAccountsResponse{
+				{Label: "a", Server: "imap.gmail.com", AuthenticationType: "oauth2"},
+				{Label: "password", Server: "imap.gmail.com", AuthenticationType: "password"},
+			}},
+			{ID: "user2", EmailAccounts: []GetUsersEmailAccountsResponse{
+				{Label: "b", Server: "imap.googlemail.com", AuthenticationType: "oauth2"},
+				{Label: "outlook", Server: "imap-mail.outlook.com", AuthenticationType: "oauth2"},
+			}},
+		}))
+	})
+	modify := func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		Must(r.ParseForm())
+		account := r.URL.Path[len("/users/"):]
+		account = account[:len(account)-len("/email_accounts/a")] + "/" + account[len(account)-1:]
+		if server.failing[account] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		server.keys[account] = r.PostForm.Get("provider_consumer_key")
+		Must(json.NewEncoder(w).Encode(ModifyEmailAccountResponse{Success: true}))
+	}
+	mux.HandleFunc("/users/user1/email_accounts/a", modify)
+	mux.HandleFunc("/users/user2/email_accounts/b", modify)
+
+	return server
+}
+
+// TestSimulatedOAuthProviderRotation tests a rotation that fails part way, is resumed from its persisted state, and completes
+func TestSimulatedOAuthProviderRotation(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+	server := newRotationTestServer(mux)
+
+	var persisted []byte
+	rotation := &OAuthProviderRotation{
+		CioLite:                cioLite,
+		OldProviderConsumerKey: "oldkey",
+		New:                    CreateOAuthProviderParams{Type: "GMAIL_OAUTH2", ProviderConsumerKey: "newkey", ProviderConsumerSecret: "newsecret"},
+		Affects:                OAuthAccountsOnServers(OAuthProviderServers["GMAIL_OAUTH2"]),
+		OnProgress: func(state OAuthProviderRotationState) {
+			var err error
+			persisted, err = json.Marshal(state)
+			Must(err)
+		},
+	}
+
+	// First run fails on one email account
+	state, err := rotation.Run(context.Background())
+	if err == nil || state.Phase != RotationAccountsListed || len(state.Failed()) != 1 || state.Failed()[0].Label != "b" {
+		t.Error("Expected rotation to fail on email account b; Got: ", state, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+	if done, total := state.Progress(); done != 1 || total != 2 {
+		t.Error("Expected progress of 1 of 2; Got: ", done, total)
+	}
+
+	// Resume from the persisted state
+	server.mu.Lock()
+	server.failing = nil
+	server.mu.Unlock()
+
+	var resumedState OAuthProviderRotationState
+	Must(json.Unmarshal(persisted, &resumedState))
+	resumed := &OAuthProviderRotation{CioLite: cioLite, New: rotation.New, State: &resumedState}
+
+	state, err = resumed.Run(context.Background())
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if err != nil || state.Phase != RotationCompleted ||
+		!reflect.DeepEqual(server.keys, map[string]string{"user1/a": "newkey", "user2/b": "newkey"}) ||
+		!reflect.DeepEqual(server.providers, map[string]string{"newkey": "GMAIL_OAUTH2", "mskey": "MSLIVECONNECT_OAUTH2"}) {
+		t.Error("Expected completed rotation; Got: ", state, server.keys, server.providers, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+}
+
+// TestSimulatedOAuthProviderRotationRollback tests rolling back a failed rotation
+func TestSimulatedOAuthProviderRotationRollback(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+	server := newRotationTestServer(mux)
+
+	rotation := &OAuthProviderRotation{
+		CioLite:                cioLite,
+		OldProviderConsumerKey: "oldkey",
+		New:                    CreateOAuthProviderParams{Type: "GMAIL_OAUTH2", ProviderConsumerKey: "newkey", ProviderConsumerSecret: "newsecret"},
+		Affects:                OAuthAccountsOnServers(OAuthProviderServers["GMAIL_OAUTH2"]),
+	}
+
+	// Mismatched type, or no Affects, fails before creating anything
+	mismatched := &OAuthProviderRotation{CioLite: cioLite, OldProviderConsumerKey: "mskey", New: CreateOAuthProviderParams{Type: "GMAIL_OAUTH2"}, Affects: rotation.Affects}
+	if _, err := mismatched.Run(context.Background()); err == nil {
+		t.Error("Expected error for mismatched OAuth provider type")
+	}
+	unscoped := &OAuthProviderRotation{CioLite: cioLite, OldProviderConsumerKey: "oldkey", New: rotation.New}
+	if _, err := unscoped.Run(context.Background()); err == nil || len(server.providers) != 2 {
+		t.Error("Expected error without Affects; Got: ", server.providers, "; With Error: ", err)
+	}
+
+	if _, err := rotation.Run(context.Background()); err == nil {
+		t.Error("Expected rotation to fail")
+	}
+
+	state, err := rotation.Rollback(context.Background())
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if err != nil || state.Phase != RotationRolledBack || state.Accounts[0].Status != RotatedAccountRolledBack ||
+		!reflect.DeepEqual(server.keys, map[string]string{"user1/a": "oldkey", "user2/b": "oldkey"}) ||
+		!reflect.DeepEqual(server.providers, map[string]string{"oldkey": "GMAIL_OAUTH2", "mskey": "MSLIVECONNECT_OAUTH2"}) {
+		t.Error("Expected rolled back rotation; Got: ", state, server.keys, server.providers, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+}
@@ -0,0 +1,225 @@
+package ciolite
+
+// Sender reporting that supports: https://context.io/docs/lite/users/email_accounts/folders
+// and: https://context.io/docs/lite/users/email_accounts/folders/messages
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SenderStats is the aggregated messages of a sender email address or domain
+type SenderStats struct {
+	// Key is the lowercased email address or domain
+	Key string
+
+	// Name is the most recent display name of the sender (always empty for domains)
+	Name string
+
+	Messages int
+	Read     int
+	Unread   int
+
+	FirstSeen time.Time
+	LastSeen  time.Time
+
+	// Lists are the List-Id of the mailing lists the messages were sent to
+	Lists []string
+
+	// Unsubscribe is the mailing list information of the most recent message that can be unsubscribed from
+	Unsubscribe MailingList
+
+	Attachments     int
+	AttachmentBytes int
+}
+
+// ReadRatio returns the fraction of messages that have been read, or 0 if there are none
+func (stats SenderStats) ReadRatio() float64 {
+	if stats.Messages == 0 {
+		return 0
+	}
+	return float64(stats.Read) / float64(stats.Messages)
+}
+
+// SenderReport is the report of messages aggregated by sender, with Addresses and Domains
+// sorted by the number of messages (most first).
+type SenderReport struct {
+	Messages  int
+	Addresses []SenderStats
+	Domains   []SenderStats
+}
+
+// SenderAggregator aggregates messages by sender email address and domain.
+// Messages in more than one folder (such as Gmail's All Mail) are only counted once.
+// The zero value is ready to use, but is not safe for concurrent use.
+type SenderAggregator struct {
+	addresses map[string]*SenderStats
+	domains   map[string]*SenderStats
+	seen      map[string]bool
+	messages  int
+}
+
+// Add aggregates the message, returning false if it was already added or has no sender.
+// Messages are deduplicated by EmailMessageID or MessageID, falling back to the sender, subject, and sent time
+// (messages without a sent time or ids are always counted).
+// Read counts require messages listed with IncludeFlags.
+func (aggregator *SenderAggregator) Add(message GetUsersEmailAccountFolderMessagesResponse) bool {
+	if aggregator.seen == nil {
+		aggregator.addresses = make(map[string]*SenderStats)
+		aggregator.domains = make(map[string]*SenderStats)
+		aggregator.seen = make(map[string]bool)
+	}
+
+	list := ParseMailingList(message)
+	if len(list.Sender.Email) == 0 {
+		return false
+	}
+
+	id := message.EmailMessageID
+	if len(id) == 0 {
+		id = message.MessageID
+	}
+	if len(id) == 0 && message.SentAt > 0 {
+		// Prefixed so it can not collide with an id
+		id = "\x00" + strings.ToLower(list.Sender.Email) + "\x00" + message.Subject + "\x00" + strconv.Itoa(message.SentAt)
+	}
+	if len(id) > 0 {
+		if aggregator.seen[id] {
+			return false
+		}
+		aggregator.seen[id] = true
+	}
+	aggregator.messages++
+
+	seenAt := message.ReceivedAtTime()
+	if seenAt.IsZero() {
+		seenAt = message.SentAtTime()
+	}
+
+	address := senderStats(aggregator.addresses, list.Sender.Email)
+	address.add(message, list, seenAt)
+	if len(list.Sender.Name) > 0 && !seenAt.Before(address.LastSeen) {
+		address.Name = list.Sender.Name
+	}
+	if len(list.Sender.Domain) > 0 {
+		senderStats(aggregator.domains, list.Sender.Domain).add(message, list, seenAt)
+	}
+	return true
+}
+
+// Report returns the SenderReport of the messages added so far
+func (aggregator *SenderAggregator) Report() SenderReport {
+	return SenderReport{
+		Messages:  aggregator.messages,
+		Addresses: sortedSenderStats(aggregator.addresses),
+		Domains:   sortedSenderStats(aggregator.domains),
+	}
+}
+
+// SenderReportParams data struct.
+// Optional: Folders (defaults to all folders, from GetUserEmailAccountsFolders),
+// and PageSize (the Limit of each GetAllUserEmailAccountsFolderMessages page, defaults to 100).
+type SenderReportParams struct {
+	Folders  []string
+	PageSize int
+}
+
+// GetSenderReport walks all messages in the folders of an email account, aggregating them by sender
+// email address and domain with a SenderAggregator.
+func (cioLite CioLite) GetSenderReport(ctx context.Context, userID string, label string, params SenderReportParams) (SenderReport, error) {
+	cioLite = cioLite.WithContext(ctx)
+
+	folders := params.Folders
+	if len(folders) == 0 {
+		allFolders, err := cioLite.GetUserEmailAccountsFolders(userID, label, GetUserEmailAccountsFoldersParams{})
+		if err != nil {
+			return SenderReport{}, err
+		}
+		for _, folder := range allFolders {
+			folders = append(folders, folder.Name)
+		}
+	}
+
+	queryValues := GetUserEmailAccountsFolderMessageParams{IncludeFlags: true, Limit: params.PageSize}
+
+	var aggregator SenderAggregator
+	for _, folder := range folders {
+		messages, err := cioLite.GetAllUserEmailAccountsFolderMessages(userID, label, folder, queryValues)
+		if ctx.Err() != nil {
+			return aggregator.Report(), errors.Wrap(ctx.Err(), "CIO: Stopped sender report")
+		}
+		if err != nil {
+			return aggregator.Report(), errors.Wrap(err, "CIO: Unable to list messages in folder "+folder)
+		}
+		for _, message := range messages {
+			aggregator.Add(message)
+		}
+	}
+
+	return aggregator.Report(), nil
+}
+
+// add aggregates the message into the stats
+func (stats *SenderStats) add(message GetUsersEmailAccountFolderMessagesResponse, list MailingList, seenAt time.Time) {
+	stats.Messages++
+	if message.Flags.Read {
+		stats.Read++
+	} else {
+		stats.Unread++
+	}
+
+	if !seenAt.IsZero() {
+		if stats.FirstSeen.IsZero() || seenAt.Before(stats.FirstSeen) {
+			stats.FirstSeen = seenAt
+		}
+		if !seenAt.Before(stats.LastSeen) {
+			stats.LastSeen = seenAt
+			if list.CanUnsubscribe() {
+				stats.Unsubscribe = list
+			}
+		}
+	}
+	if list.CanUnsubscribe() && !stats.Unsubscribe.CanUnsubscribe() {
+		stats.Unsubscribe = list
+	}
+
+	if len(list.ID) > 0 && !containsFoldString(stats.Lists, list.ID) {
+		stats.Lists = append(stats.Lists, list.ID)
+		sort.Strings(stats.Lists)
+	}
+
+	for _, attachment := range message.Attachments {
+		stats.Attachments++
+		stats.AttachmentBytes += attachment.Size
+	}
+}
+
+// senderStats returns the stats for the key, adding them if not yet in the map
+func senderStats(stats map[string]*SenderStats, key string) *SenderStats {
+	if existing, ok := stats[key]; ok {
+		return existing
+	}
+	added := &SenderStats{Key: key}
+	stats[key] = added
+	return added
+}
+
+// sortedSenderStats returns the stats sorted by the number of messages (most first), then by key
+func sortedSenderStats(stats map[string]*SenderStats) []SenderStats {
+	sorted := make([]SenderStats, 0, len(stats))
+	for _, s := range stats {
+		sorted = append(sorted, *s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Messages != sorted[j].Messages {
+			return sorted[i].Messages > sorted[j].Messages
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
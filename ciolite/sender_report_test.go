@@ -0,0 +1,106 @@
+package ciolite
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSimulatedGetSenderReport tests GetSenderReport with a simulated server
+func TestSimulatedGetSenderReport(t *testing.T) {
+	t.Parallel()
+
+	cioLite, logger, testServer, mux := NewTestCioLiteWithLoggerAndTestServer(t)
+	defer testServer.Close()
+
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `[{"name": "INBOX"}, {"name": "Archive"}]`)
+		Must(err)
+	})
+
+	messages := map[string][]string{
+		"INBOX": {
+			`{"email_message_id": "1", "received_at": 1000, "flags": {"read": true},
+				"addresses": {"from": [{"email": "News@Example.com", "name": "Old Name"}]},
+				"list_headers": ["List-Id: <news.example.com>", "List-Unsubscribe: <https://example.com/unsub>"],
+				"attachments": [{"size": 100}, {"size": 50}]}`,
+			`{"email_message_id": "2", "received_at": 3000,
+				"addresses": {"from": [{"email": "news@example.com", "name": "Example News"}]}}`,
+		},
+		"Archive": {
+			`{"email_message_id": "1", "received_at": 1000, "flags": {"read": true},
+				"addresses": {"from": [{"email": "news@example.com"}]}}`,
+			`{"email_message_id": "3", "received_at": 2000, "flags": {"read": true},
+				"addresses": {"from": [{"email": "alerts@example.com"}]}}`,
+			`{"email_message_id": "4", "received_at": 500,
+				"addresses": {"from": [{"email": "friend@other.org"}]}}`,
+		},
+	}
+	serveMessages := func(folder string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("include_flags") != "1" || r.URL.Query().Get("limit") != "2" {
+				t.Error("Expected include_flags and limit; Got: ", r.URL.RawQuery)
+			}
+			page := messages[folder]
+			if r.URL.Query().Get("offset") == "2" {
+				page = page[2:]
+			} else if len(page) > 2 {
+				page = page[:2]
+			}
+			body := "["
+			for i, message := range page {
+				if i > 0 {
+					body += ","
+				}
+				body += message
+			}
+			_, err := io.WriteString(w, body+"]")
+			Must(err)
+		}
+	}
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/INBOX/messages", serveMessages("INBOX"))
+	mux.HandleFunc("/users/fakeUserID/email_accounts/fakeLabel/folders/Archive/messages", serveMessages("Archive"))
+
+	report, err := cioLite.GetSenderReport(context.Background(), "fakeUserID", "fakeLabel", SenderReportParams{PageSize: 2})
+	if err != nil || report.Messages != 4 || len(report.Addresses) != 3 || len(report.Domains) != 2 {
+		t.Fatal("Expected 4 messages from 3 addresses and 2 domains; Got: ", report, "; With Error: ", err, "; With Log: ", logger.String())
+	}
+
+	news := report.Addresses[0]
+	if news.Key != "news@example.com" || news.Name != "Example News" || news.Messages != 2 || news.Read != 1 || news.Unread != 1 ||
+		news.ReadRatio() != 0.5 || !news.FirstSeen.Equal(time.Unix(1000, 0)) || !news.LastSeen.Equal(time.Unix(3000, 0)) ||
+		len(news.Lists) != 1 || news.Lists[0] != "news.example.com" || news.Unsubscribe.UnsubscribeURLs[0] != "https://example.com/unsub" ||
+		news.Attachments != 2 || news.AttachmentBytes != 150 {
+		t.Error("Unexpected news@example.com stats: ", news)
+	}
+
+	if report.Addresses[1].Key != "alerts@example.com" || report.Addresses[2].Key != "friend@other.org" {
+		t.Error("Expected addresses sorted by messages then key; Got: ", report.Addresses)
+	}
+
+	domain := report.Domains[0]
+	if domain.Key != "example.com" || domain.Name != "" || domain.Messages != 3 || domain.Read != 2 ||
+		!domain.FirstSeen.Equal(time.Unix(1000, 0)) || !domain.LastSeen.Equal(time.Unix(3000, 0)) {
+		t.Error("Unexpected example.com stats: ", domain)
+	}
+
+	// Messages without a sender are not counted
+	var aggregator SenderAggregator
+	if aggregator.Add(GetUsersEmailAccountFolderMessagesResponse{EmailMessageID: "5"}) || aggregator.Report().Messages != 0 {
+		t.Error("Expected message without a sender to be skipped; Got: ", aggregator.Report())
+	}
+
+	// Messages without ids are deduplicated by sender, subject, and sent time
+	var noID GetUsersEmailAccountFolderMessagesResponse
+	Must(json.Unmarshal([]byte(`{"subject": "Hi", "sent_at": 1000, "addresses": {"from": [{"email": "a@example.com"}]}}`), &noID))
+	if !aggregator.Add(noID) || aggregator.Add(noID) || aggregator.Report().Messages != 1 {
+		t.Error("Expected message without an id to be counted once; Got: ", aggregator.Report())
+	}
+	noID.SentAt = 2000
+	if !aggregator.Add(noID) || aggregator.Report().Messages != 2 {
+		t.Error("Expected message sent at another time to be counted; Got: ", aggregator.Report())
+	}
+}
@@ -0,0 +1,123 @@
+package cioutil
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// LeveledLogger interface for leveled, structured logging.
+// keysAndValues are alternating string keys and values, such as: "statusCode", 200, "url", cioURL.
+// All request logging goes through this interface, so the field names are the same regardless of backend.
+// *slog.Logger implements this interface directly.
+type LeveledLogger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+}
+
+// Field names used by the request and response logging
+const (
+	LogFieldHTTPMethod     = "httpMethod"
+	LogFieldURL            = "url"
+	LogFieldPayload        = "payload"
+	LogFieldStatusCode     = "statusCode"
+	LogFieldPayloadSnippet = "payloadSnippet"
+	LogFieldError          = "error"
+)
+
+// NewLeveledLogger returns a LeveledLogger for the provided Logger:
+// the Logger itself if it already is a LeveledLogger,
+// a LogrusLogger if it is a *logrus.Logger or *logrus.Entry,
+// a StdLogger for any other Logger (such as *log.Logger), or nil if the Logger is nil.
+func NewLeveledLogger(logger Logger) LeveledLogger {
+	switch l := logger.(type) {
+	case nil:
+		return nil
+	case LeveledLogger:
+		return l
+	case *logrus.Logger:
+		if l == nil {
+			return nil
+		}
+		return LogrusLogger{l}
+	case *logrus.Entry:
+		if l == nil {
+			return nil
+		}
+		return LogrusLogger{l}
+	default:
+		return StdLogger{l}
+	}
+}
+
+// StdLogger adapts a Printf Logger (such as *log.Logger) to a LeveledLogger,
+// printing the level, message, and key=value fields on a single line.
+type StdLogger struct {
+	Logger Logger
+}
+
+// Debug implements LeveledLogger
+func (l StdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.Logger.Printf("%s\n", formatLine("DEBUG", msg, keysAndValues))
+}
+
+// Warn implements LeveledLogger
+func (l StdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.Logger.Printf("%s\n", formatLine("WARN", msg, keysAndValues))
+}
+
+// formatLine formats the level, message, and key=value fields as a single line
+func formatLine(level string, msg string, keysAndValues []interface{}) string {
+	var buf bytes.Buffer
+	buf.WriteString(level)
+	buf.WriteString(" ")
+	buf.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, value := keyAndValue(keysAndValues, i)
+		_, _ = fmt.Fprintf(&buf, " %s=%q", key, fmt.Sprint(value))
+	}
+	return buf.String()
+}
+
+// logrusFieldLogger is the part of *logrus.Logger and *logrus.Entry used by LogrusLogger
+type logrusFieldLogger interface {
+	WithFields(fields logrus.Fields) *logrus.Entry
+}
+
+// LogrusLogger adapts a *logrus.Logger or *logrus.Entry to a LeveledLogger, using structured fields
+type LogrusLogger struct {
+	Logger logrusFieldLogger
+}
+
+// Debug implements LeveledLogger
+func (l LogrusLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.Logger.WithFields(logrusFields(keysAndValues)).Debug(msg)
+}
+
+// Warn implements LeveledLogger
+func (l LogrusLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.Logger.WithFields(logrusFields(keysAndValues)).Warn(msg)
+}
+
+// logrusFields converts alternating keys and values to logrus.Fields
+func logrusFields(keysAndValues []interface{}) logrus.Fields {
+	fields := make(logrus.Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, value := keyAndValue(keysAndValues, i)
+		fields[key] = value
+	}
+	return fields
+}
+
+// keyAndValue returns the key at index i and its value, handling non-string keys and a missing final value
+func keyAndValue(keysAndValues []interface{}, i int) (string, interface{}) {
+	key, ok := keysAndValues[i].(string)
+	if !ok {
+		key = fmt.Sprint(keysAndValues[i])
+	}
+	if i+1 >= len(keysAndValues) {
+		return key, nil
+	}
+	return key, keysAndValues[i+1]
+}
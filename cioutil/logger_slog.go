@@ -0,0 +1,17 @@
+//go:build go1.21
+
+package cioutil
+
+import "log/slog"
+
+// *slog.Logger implements LeveledLogger without any adapter
+var _ LeveledLogger = (*slog.Logger)(nil)
+
+// NewSlogLogger returns a LeveledLogger for the *slog.Logger (or slog.Default() if nil).
+// The *slog.Logger is returned as is, since it already implements LeveledLogger.
+func NewSlogLogger(logger *slog.Logger) LeveledLogger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package cioutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// TestSlogLogger tests that a *slog.Logger can be used as a LeveledLogger
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	logRequest(logger, Redactor{}, "POST", "https://cio/users", nil)
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatal("Expected json log line; Got: ", buf.String())
+	}
+
+	if fields["msg"] != "Creating new request to CIO" || fields["level"] != "DEBUG" ||
+		fields[LogFieldHTTPMethod] != "POST" || fields[LogFieldURL] != "https://cio/users" {
+		t.Error("Unexpected slog fields: ", fields)
+	}
+
+	if NewSlogLogger(nil) == nil {
+		t.Error("Expected default *slog.Logger for nil")
+	}
+}
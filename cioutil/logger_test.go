@@ -0,0 +1,94 @@
+package cioutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// TestNewLeveledLogger tests that each type of Logger is adapted to the right LeveledLogger
+func TestNewLeveledLogger(t *testing.T) {
+	t.Parallel()
+
+	if NewLeveledLogger(nil) != nil {
+		t.Error("Expected nil LeveledLogger for nil Logger")
+	}
+
+	var nilLogrus *logrus.Logger
+	if NewLeveledLogger(nilLogrus) != nil {
+		t.Error("Expected nil LeveledLogger for nil *logrus.Logger")
+	}
+
+	if _, ok := NewLeveledLogger(log.New(&bytes.Buffer{}, "", 0)).(StdLogger); !ok {
+		t.Error("Expected StdLogger for *log.Logger")
+	}
+
+	if _, ok := NewLeveledLogger(logrus.New()).(LogrusLogger); !ok {
+		t.Error("Expected LogrusLogger for *logrus.Logger")
+	}
+
+	if _, ok := NewLeveledLogger(logrus.NewEntry(logrus.New())).(LogrusLogger); !ok {
+		t.Error("Expected LogrusLogger for *logrus.Entry")
+	}
+}
+
+// TestStdLogger tests the single line output of StdLogger
+func TestStdLogger(t *testing.T) {
+	t.Parallel()
+
+	logger := &TestLogger{Buffer: &bytes.Buffer{}}
+	NewLeveledLogger(logger).Warn("Received response from CIO", LogFieldStatusCode, 500, LogFieldError, errors.New("bad"))
+
+	expected := "WARN Received response from CIO statusCode=\"500\" error=\"bad\"\n"
+	if logger.String() != expected {
+		t.Error("Expected: ", expected, "; Got: ", logger.String())
+	}
+}
+
+// TestLogrusLogger tests that LogrusLogger uses structured fields
+func TestLogrusLogger(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	logrusLogger := logrus.New()
+	logrusLogger.Out = buf
+	logrusLogger.Formatter = &logrus.JSONFormatter{}
+	logrusLogger.Level = logrus.DebugLevel
+
+	NewLeveledLogger(logrusLogger.WithField("app", "test")).Debug("Creating new request to CIO", LogFieldHTTPMethod, "GET", LogFieldURL, "https://cio/users")
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatal("Expected json log line; Got: ", buf.String())
+	}
+
+	if fields["msg"] != "Creating new request to CIO" || fields["level"] != "debug" || fields["app"] != "test" ||
+		fields[LogFieldHTTPMethod] != "GET" || fields[LogFieldURL] != "https://cio/users" {
+		t.Error("Unexpected logrus fields: ", fields)
+	}
+}
+
+// TestLogResponseLevels tests that failed responses are logged at warn level, and others at debug level
+func TestLogResponseLevels(t *testing.T) {
+	t.Parallel()
+
+	logger := &TestLogger{Buffer: &bytes.Buffer{}}
+	leveled := NewLeveledLogger(logger)
+
+	logResponse(leveled, Redactor{}, false, "GET", "https://cio/users", 200, `{"ok":true}`, nil)
+	logResponse(leveled, Redactor{}, true, "GET", "https://cio/users", 500, "", errors.New("retrying"))
+	logResponse(leveled, Redactor{}, false, "GET", "https://cio/users", 500, "", errors.New("failed"))
+
+	lines := strings.Split(strings.TrimSpace(logger.String()), "\n")
+	if len(lines) != 3 ||
+		!strings.HasPrefix(lines[0], "DEBUG Received response from CIO httpMethod=\"GET\" url=\"https://cio/users\" statusCode=\"200\"") ||
+		!strings.HasPrefix(lines[1], "DEBUG ") ||
+		!strings.HasPrefix(lines[2], "WARN ") || !strings.HasSuffix(lines[2], "error=\"failed\"") {
+		t.Error("Unexpected log output: ", logger.String())
+	}
+}
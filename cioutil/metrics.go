@@ -0,0 +1,105 @@
+package cioutil
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Metrics interface allows injection of a metrics collector,
+// which DoFormRequest reports every request attempt to.
+type Metrics interface {
+	ObserveRequest(metric RequestMetric)
+}
+
+// RequestMetric holds the measurements of a single request attempt to CIO.
+// Endpoint is the path template (such as /users/{id}/email_accounts/{label}),
+// not the actual path, to keep the cardinality of endpoints low.
+type RequestMetric struct {
+	Method   string
+	Endpoint string
+
+	// StatusCode is 0 if no response was received
+	StatusCode int
+	Duration   time.Duration
+
+	// Retry is true if this attempt was a retry of a failed attempt
+	Retry bool
+
+	BytesSent     int
+	BytesReceived int
+
+	Err error
+}
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram buckets used by ExpvarMetrics
+var DefaultLatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// ExpvarMetrics is a Metrics implementation that publishes to expvar (and therefore /debug/vars).
+// It publishes a map of "METHOD /endpoint" to a map of counters:
+// requests, retries, errors, bytes_sent, bytes_received, status_<code>,
+// latency_ms_sum, and cumulative latency_ms_le_<bucket> histogram counters.
+type ExpvarMetrics struct {
+	Endpoints      *expvar.Map
+	LatencyBuckets []time.Duration
+
+	mu sync.Mutex
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics published to expvar with the given name.
+// If a map has already been published with that name, it is reused.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	endpoints, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		endpoints = expvar.NewMap(name)
+	}
+	return &ExpvarMetrics{Endpoints: endpoints, LatencyBuckets: DefaultLatencyBuckets}
+}
+
+// ObserveRequest implements Metrics, adding the request attempt to the endpoint's counters
+func (m *ExpvarMetrics) ObserveRequest(metric RequestMetric) {
+	counters := m.endpoint(metric.Method + " " + metric.Endpoint)
+
+	counters.Add("requests", 1)
+	if metric.Retry {
+		counters.Add("retries", 1)
+	}
+	if metric.Err != nil {
+		counters.Add("errors", 1)
+	}
+	counters.Add("bytes_sent", int64(metric.BytesSent))
+	counters.Add("bytes_received", int64(metric.BytesReceived))
+	counters.Add(fmt.Sprintf("status_%d", metric.StatusCode), 1)
+
+	counters.Add("latency_ms_sum", int64(metric.Duration/time.Millisecond))
+	for _, bucket := range m.LatencyBuckets {
+		if metric.Duration <= bucket {
+			counters.Add(fmt.Sprintf("latency_ms_le_%d", bucket/time.Millisecond), 1)
+		}
+	}
+	counters.Add("latency_ms_le_inf", 1)
+}
+
+// endpoint returns the counters map for the endpoint, creating it if needed
+func (m *ExpvarMetrics) endpoint(key string) *expvar.Map {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if counters, ok := m.Endpoints.Get(key).(*expvar.Map); ok {
+		return counters
+	}
+	counters := new(expvar.Map).Init()
+	m.Endpoints.Set(key, counters)
+	return counters
+}
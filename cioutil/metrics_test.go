@@ -0,0 +1,115 @@
+package cioutil
+
+import (
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testMetrics records every RequestMetric it observes
+type testMetrics struct {
+	mu      sync.Mutex
+	metrics []RequestMetric
+}
+
+// ObserveRequest implements Metrics
+func (m *testMetrics) ObserveRequest(metric RequestMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics = append(m.metrics, metric)
+}
+
+// TestDoFormRequestMetrics tests that each attempt is reported with the endpoint template
+func TestDoFormRequestMetrics(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, err := io.WriteString(w, `{"success":true}`)
+		if err != nil {
+			panic(err)
+		}
+	}))
+	defer testServer.Close()
+
+	metrics := &testMetrics{}
+	cio := NewCio("key", "secret", nil, testServer.URL, 5*time.Second)
+	cio.RetryServerErr = true
+	cio.Metrics = metrics
+
+	var result map[string]interface{}
+	err := cio.DoFormRequest(ClientRequest{
+		Method:     "POST",
+		Path:       "/users/{id}",
+		PathValues: PathValues{"id": "abc123"},
+		FormValues: struct {
+			FirstName string `json:"first_name"`
+		}{"test"},
+	}, &result)
+
+	if err != nil {
+		t.Fatal("Expected successful request; Got: ", err)
+	}
+
+	if len(metrics.metrics) != 2 {
+		t.Fatal("Expected 2 request metrics; Got: ", metrics.metrics)
+	}
+
+	first, second := metrics.metrics[0], metrics.metrics[1]
+	if first.Endpoint != "/users/{id}" || first.Method != "POST" || first.StatusCode != 503 || first.Retry || first.Err == nil ||
+		first.BytesSent != len("first_name=test") || first.BytesReceived != len(`{"success":true}`) {
+		t.Error("Unexpected first request metric: ", first)
+	}
+	if second.Endpoint != "/users/{id}" || second.StatusCode != 200 || !second.Retry || second.Err != nil {
+		t.Error("Unexpected second request metric: ", second)
+	}
+}
+
+// TestExpvarMetrics tests that ExpvarMetrics publishes counters per endpoint
+func TestExpvarMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewExpvarMetrics("cioutil_test_expvar_metrics")
+	if NewExpvarMetrics("cioutil_test_expvar_metrics").Endpoints != metrics.Endpoints {
+		t.Error("Expected NewExpvarMetrics to reuse the published map")
+	}
+
+	metrics.ObserveRequest(RequestMetric{Method: "GET", Endpoint: "/users/{id}", StatusCode: 200, Duration: 75 * time.Millisecond, BytesReceived: 10})
+	metrics.ObserveRequest(RequestMetric{Method: "GET", Endpoint: "/users/{id}", StatusCode: 500, Duration: 3 * time.Second, Retry: true, Err: io.EOF})
+
+	counters, ok := metrics.Endpoints.Get("GET /users/{id}").(*expvar.Map)
+	if !ok {
+		t.Fatal("Expected endpoint counters; Got: ", metrics.Endpoints.String())
+	}
+
+	expected := map[string]string{
+		"requests":           "2",
+		"retries":            "1",
+		"errors":             "1",
+		"bytes_received":     "10",
+		"status_200":         "1",
+		"status_500":         "1",
+		"latency_ms_le_50":   "",
+		"latency_ms_le_100":  "1",
+		"latency_ms_le_5000": "2",
+		"latency_ms_le_inf":  "2",
+		"latency_ms_sum":     "3075",
+	}
+	for key, value := range expected {
+		got := ""
+		if v := counters.Get(key); v != nil {
+			got = v.String()
+		}
+		if got != value {
+			t.Error("Expected ", key, " of: ", value, "; Got: ", got)
+		}
+	}
+}
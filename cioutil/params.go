@@ -4,79 +4,251 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
-// FormValues returns valid FormValues for CIO
-func FormValues(cioFormValueParams interface{}) url.Values {
+// FormEncoder can be implemented by parameter field types that need to control
+// their own encoding. EncodeFormValues should add the value(s) for key to values.
+type FormEncoder interface {
+	EncodeFormValues(key string, values url.Values) error
+}
+
+var (
+	// timeType is the reflect.Type of time.Time, which is encoded as a Unix timestamp
+	timeType = reflect.TypeOf(time.Time{})
+
+	// formEncoderType is the reflect.Type of the FormEncoder interface
+	formEncoderType = reflect.TypeOf((*FormEncoder)(nil)).Elem()
+)
+
+// FormValues returns valid FormValues for CIO.
+// Field names are taken from the json tag of each struct field. Fields tagged
+// with omitempty are not sent if they hold their zero value, while fields without
+// it are always sent (bools as 1 or 0). Nil pointer fields are never sent, and
+// non-nil pointer fields are always sent, allowing explicit false/zero values.
+// Slices are sent as repeated values, time.Time as a Unix timestamp, embedded
+// structs are flattened, nested structs are sent as name[field], and any type
+// implementing FormEncoder encodes itself.
+func FormValues(cioFormValueParams interface{}) (url.Values, error) {
 
 	// Values
 	values := url.Values{}
 
 	// If uninitialized, return empty url.Values
 	if cioFormValueParams == nil {
-		return values
+		return values, nil
 	}
 
-	// dynamically iterate through struct fields
+	// Dereference any pointers to the params struct
 	refVal := reflect.ValueOf(cioFormValueParams)
-	refType := reflect.TypeOf(cioFormValueParams)
+	for refVal.Kind() == reflect.Ptr {
+		if refVal.IsNil() {
+			return values, nil
+		}
+		refVal = refVal.Elem()
+	}
+	if refVal.Kind() != reflect.Struct {
+		return nil, errors.Errorf("Unexpected parameters type: %s", refVal.Type())
+	}
+
+	if err := encodeStruct(values, "", refVal); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// QueryString returns a query string
+func QueryString(cioQueryValueParams interface{}) (string, error) {
+
+	// Encode parameters
+	values, err := FormValues(cioQueryValueParams)
+	if err != nil {
+		return "", err
+	}
+	encoded := values.Encode()
+	if encoded == "" {
+		return encoded, nil
+	}
+
+	// Format
+	return fmt.Sprintf("?%s", encoded), nil
+}
+
+// encodeStruct dynamically iterates through the struct fields, adding each to values.
+// If prefix is set, the field names are nested within it as prefix[name].
+func encodeStruct(values url.Values, prefix string, refVal reflect.Value) error {
+	refType := refVal.Type()
 	for i, numFields := 0, refVal.NumField(); i < numFields; i++ {
 		fieldValue := refVal.Field(i)
 		fieldType := refType.Field(i)
 
-		// dynamically choose how to fill the values based on field type
-		// and set the key to the json tag name
-		switch fieldValue.Kind() {
-
-		case reflect.String:
-			v := fieldValue.String()
-			if len(v) > 0 {
-				values.Set(jsonName(fieldType), v)
+		// Embedded structs without a json tag are flattened into the parent
+		if fieldType.Anonymous && len(fieldType.Tag.Get("json")) == 0 {
+			embedded := fieldValue
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
 			}
-
-		case reflect.Bool:
-			v := fieldValue.Bool()
-			if v {
-				values.Set(jsonName(fieldType), "1")
+			if embedded.Kind() == reflect.Struct {
+				if err := encodeStruct(values, prefix, embedded); err != nil {
+					return err
+				}
+				continue
 			}
+		}
 
-		case reflect.Int:
-			v := fieldValue.Int()
-			if v != 0 {
-				values.Set(jsonName(fieldType), fmt.Sprintf("%d", v))
+		// Skip unexported fields
+		if len(fieldType.PkgPath) > 0 {
+			continue
+		}
+
+		name, omitEmpty, err := formName(fieldType)
+		if err != nil {
+			return err
+		}
+		if name == "-" {
+			continue
+		}
+		if len(prefix) > 0 {
+			name = fmt.Sprintf("%s[%s]", prefix, name)
+		}
+
+		if err := encodeValue(values, name, fieldValue, omitEmpty); err != nil {
+			return errors.Wrapf(err, "Unable to encode parameter %s", fieldType.Name)
+		}
+	}
+	return nil
+}
+
+// encodeValue dynamically chooses how to fill the values based on the field type
+func encodeValue(values url.Values, key string, fieldValue reflect.Value, omitEmpty bool) error {
+
+	// Nil pointers are never sent, non-nil pointers are always sent (allowing explicit zero values)
+	if fieldValue.Kind() == reflect.Ptr {
+		if fieldValue.IsNil() {
+			return nil
+		}
+		return encodeValue(values, key, fieldValue.Elem(), false)
+	}
+
+	if omitEmpty && isEmptyValue(fieldValue) {
+		return nil
+	}
+
+	if encoder, ok := asFormEncoder(fieldValue); ok {
+		return encoder.EncodeFormValues(key, values)
+	}
+
+	switch fieldValue.Kind() {
+
+	case reflect.String:
+		values.Add(key, fieldValue.String())
+
+	case reflect.Bool:
+		if fieldValue.Bool() {
+			values.Add(key, "1")
+		} else {
+			values.Add(key, "0")
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		values.Add(key, strconv.FormatInt(fieldValue.Int(), 10))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		values.Add(key, strconv.FormatUint(fieldValue.Uint(), 10))
+
+	case reflect.Float32, reflect.Float64:
+		values.Add(key, strconv.FormatFloat(fieldValue.Float(), 'f', -1, fieldValue.Type().Bits()))
+
+	case reflect.Slice, reflect.Array:
+		for i, length := 0, fieldValue.Len(); i < length; i++ {
+			if err := encodeValue(values, key, fieldValue.Index(i), false); err != nil {
+				return err
 			}
+		}
+
+	case reflect.Interface:
+		if fieldValue.IsNil() {
+			return nil
+		}
+		return encodeValue(values, key, fieldValue.Elem(), omitEmpty)
 
-		default:
-			panic("Unexpected parameter type: " + fieldValue.Kind().String())
+	case reflect.Struct:
+		if fieldValue.Type() == timeType {
+			values.Add(key, strconv.FormatInt(fieldValue.Interface().(time.Time).Unix(), 10))
+			return nil
 		}
+		return encodeStruct(values, key, fieldValue)
+
+	default:
+		return errors.Errorf("Unexpected parameter type: %s", fieldValue.Type())
 	}
 
-	return values
+	return nil
 }
 
-// QueryString returns a query string
-func QueryString(cioQueryValueParams interface{}) string {
-
-	// Encode parameters
-	encoded := FormValues(cioQueryValueParams).Encode()
-	if encoded == "" {
-		return encoded
+// asFormEncoder returns the FormEncoder implemented by the value (or by a pointer to it), if any
+func asFormEncoder(fieldValue reflect.Value) (FormEncoder, bool) {
+	if !fieldValue.CanInterface() {
+		return nil, false
 	}
+	if fieldValue.Type().Implements(formEncoderType) {
+		encoder, ok := fieldValue.Interface().(FormEncoder)
+		return encoder, ok
+	}
+	if reflect.PtrTo(fieldValue.Type()).Implements(formEncoderType) {
+		ptr := reflect.New(fieldValue.Type())
+		ptr.Elem().Set(fieldValue)
+		return ptr.Interface().(FormEncoder), true
+	}
+	return nil, false
+}
 
-	// Format
-	return fmt.Sprintf("?%s", encoded)
+// isEmptyValue returns true if the value is the zero value for omitempty purposes
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
 }
 
-// jsonName returns the json name based on the json tag of the struct field
-func jsonName(sf reflect.StructField) string {
+// formName returns the name based on the json tag of the struct field,
+// and whether the omitempty option is set
+func formName(sf reflect.StructField) (string, bool, error) {
 	jsonTag := sf.Tag.Get("json")
 	indexComma := strings.Index(jsonTag, ",")
 	if len(jsonTag) == 0 || indexComma == 0 {
-		panic(fmt.Sprintf("Parameter %s missing json name tag", sf.Name))
+		return "", false, errors.Errorf("Parameter %s missing json name tag", sf.Name)
 	}
 	if indexComma >= 0 {
-		return jsonTag[:indexComma]
+		omitEmpty := false
+		for _, option := range strings.Split(jsonTag[indexComma+1:], ",") {
+			if option == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		return jsonTag[:indexComma], omitEmpty, nil
 	}
-	return jsonTag
+	return jsonTag, false, nil
 }
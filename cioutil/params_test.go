@@ -3,7 +3,9 @@ package cioutil
 import (
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestFormValues tests that the form values function returns the correct url.Values
@@ -11,12 +13,14 @@ func TestFormValuesAndQueryString(t *testing.T) {
 	t.Parallel()
 
 	params := struct {
-		StringFull  string `json:"string_full"`
-		StringEmpty string `json:"string_empty"`
-		BoolTrue    bool   `json:"bool_true"`
-		BoolFalse   bool   `json:"bool_false"`
-		IntLarge    int    `json:"int_large"`
-		IntZero     int    `json:"int_zero"`
+		StringFull  string    `json:"string_full,omitempty"`
+		StringEmpty string    `json:"string_empty,omitempty"`
+		BoolTrue    bool      `json:"bool_true,omitempty"`
+		BoolFalse   bool      `json:"bool_false,omitempty"`
+		IntLarge    int       `json:"int_large,omitempty"`
+		IntZero     int       `json:"int_zero,omitempty"`
+		TimeSet     time.Time `json:"time_set,omitempty"`
+		TimeZero    time.Time `json:"time_zero,omitempty"`
 	}{
 		StringFull:  "hello world",
 		StringEmpty: "",
@@ -24,25 +28,122 @@ func TestFormValuesAndQueryString(t *testing.T) {
 		BoolFalse:   false,
 		IntLarge:    8194723,
 		IntZero:     0,
+		TimeSet:     time.Unix(1462217246, 0),
 	}
 
 	expectedFormValues := url.Values{
 		"string_full": []string{"hello world"},
 		"bool_true":   []string{"1"},
 		"int_large":   []string{"8194723"},
+		"time_set":    []string{"1462217246"},
 	}
 
-	formValues := FormValues(params)
+	formValues, err := FormValues(params)
 
-	if !reflect.DeepEqual(formValues, expectedFormValues) {
-		t.Error("Expected form values: ", expectedFormValues, "; Got: ", formValues)
+	if err != nil || !reflect.DeepEqual(formValues, expectedFormValues) {
+		t.Error("Expected form values: ", expectedFormValues, "; Got: ", formValues, "; With Error: ", err)
 	}
 
-	expectedQueryString := "?bool_true=1&int_large=8194723&string_full=hello+world"
+	expectedQueryString := "?bool_true=1&int_large=8194723&string_full=hello+world&time_set=1462217246"
 
-	queryString := QueryString(params)
+	queryString, err := QueryString(params)
 
-	if queryString != expectedQueryString {
-		t.Error("Expected query string: ", expectedQueryString, "; Got: ", queryString)
+	if err != nil || queryString != expectedQueryString {
+		t.Error("Expected query string: ", expectedQueryString, "; Got: ", queryString, "; With Error: ", err)
+	}
+}
+
+// testFormEncoder is a custom FormEncoder that joins its values with a comma
+type testFormEncoder []string
+
+// EncodeFormValues implements FormEncoder
+func (e testFormEncoder) EncodeFormValues(key string, values url.Values) error {
+	values.Set(key, strings.Join(e, ","))
+	return nil
+}
+
+// TestFormValuesRicherTypes tests the encoding of non-omitempty, pointer, slice, nested, and custom encoded fields
+func TestFormValuesRicherTypes(t *testing.T) {
+	t.Parallel()
+
+	type Embedded struct {
+		EmbeddedField string `json:"embedded_field"`
+	}
+
+	falseValue := false
+	zeroValue := int64(0)
+
+	params := struct {
+		Embedded
+		BoolFalse   bool            `json:"bool_false"`
+		IntZero     int             `json:"int_zero"`
+		BoolPtrNil  *bool           `json:"bool_ptr_nil,omitempty"`
+		BoolPtr     *bool           `json:"bool_ptr,omitempty"`
+		Int64Ptr    *int64          `json:"int64_ptr,omitempty"`
+		Uint        uint            `json:"uint,omitempty"`
+		Float       float64         `json:"float,omitempty"`
+		Slice       []string        `json:"slice,omitempty"`
+		SliceEmpty  []string        `json:"slice_empty,omitempty"`
+		Custom      testFormEncoder `json:"custom,omitempty"`
+		Skipped     string          `json:"-"`
+		unexported  string
+		NestedField struct {
+			Inner string `json:"inner"`
+		} `json:"nested"`
+	}{
+		Embedded:   Embedded{EmbeddedField: "embedded"},
+		BoolPtr:    &falseValue,
+		Int64Ptr:   &zeroValue,
+		Uint:       42,
+		Float:      1.5,
+		Slice:      []string{"a", "b"},
+		Custom:     testFormEncoder{"x", "y"},
+		Skipped:    "skipped",
+		unexported: "unexported",
+	}
+	params.NestedField.Inner = "inner"
+
+	expectedFormValues := url.Values{
+		"embedded_field": []string{"embedded"},
+		"bool_false":     []string{"0"},
+		"int_zero":       []string{"0"},
+		"bool_ptr":       []string{"0"},
+		"int64_ptr":      []string{"0"},
+		"uint":           []string{"42"},
+		"float":          []string{"1.5"},
+		"slice":          []string{"a", "b"},
+		"custom":         []string{"x,y"},
+		"nested[inner]":  []string{"inner"},
+	}
+
+	formValues, err := FormValues(&params)
+
+	if err != nil || !reflect.DeepEqual(formValues, expectedFormValues) {
+		t.Error("Expected form values: ", expectedFormValues, "; Got: ", formValues, "; With Error: ", err)
+	}
+}
+
+// TestFormValuesErrors tests that unsupported parameters return errors instead of panicking
+func TestFormValuesErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := FormValues(struct {
+		Map map[string]string `json:"map"`
+	}{}); err == nil {
+		t.Error("Expected error for unsupported map parameter; Got: nil")
+	}
+
+	if _, err := FormValues(struct {
+		NoTag string
+	}{}); err == nil {
+		t.Error("Expected error for parameter missing json tag; Got: nil")
+	}
+
+	if _, err := QueryString("not a struct"); err == nil {
+		t.Error("Expected error for non-struct parameters; Got: nil")
+	}
+
+	if values, err := FormValues(nil); err != nil || len(values) != 0 {
+		t.Error("Expected empty form values for nil parameters; Got: ", values, "; With Error: ", err)
 	}
 }
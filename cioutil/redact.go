@@ -0,0 +1,203 @@
+package cioutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultRedactKeys are the form value, query string, and json keys redacted by a Redactor with no Keys set
+var DefaultRedactKeys = []string{
+	"password",
+	"provider_refresh_token",
+	"provider_consumer_key",
+	"provider_consumer_secret",
+	"access_token",
+	"access_token_secret",
+	"refresh_token",
+	"token",
+	"signature",
+}
+
+// Redacted is the value that replaces any redacted values
+const Redacted = "redacted"
+
+// Redactor redacts the values of sensitive keys from form values, query strings, urls,
+// json (or form encoded) payloads, and RequestErrors, before they are logged or returned.
+// The zero value redacts the DefaultRedactKeys. Keys are matched case insensitively.
+type Redactor struct {
+	Keys []string
+}
+
+// keys returns the deny-list of keys for the Redactor
+func (r Redactor) keys() []string {
+	if r.Keys == nil {
+		return DefaultRedactKeys
+	}
+	return r.Keys
+}
+
+// IsSensitive returns true if the key is in the deny-list
+func (r Redactor) IsSensitive(key string) bool {
+	for _, k := range r.keys() {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns a copy of the url.Values with the sensitive values redacted
+func (r Redactor) Values(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for k, v := range values {
+		if r.IsSensitive(k) {
+			redactedValues := make([]string, len(v))
+			for i := range v {
+				redactedValues[i] = Redacted
+			}
+			redacted[k] = redactedValues
+		} else {
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
+
+// URL returns the url with any sensitive query string values and any user info password redacted
+// (and is returned unchanged if it has none)
+func (r Redactor) URL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return r.Text(rawURL)
+	}
+	redacted := false
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+			redacted = true
+		}
+	}
+	if len(u.RawQuery) > 0 {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return r.Text(rawURL)
+		}
+		for key := range query {
+			if r.IsSensitive(key) {
+				u.RawQuery = r.Values(query).Encode()
+				redacted = true
+				break
+			}
+		}
+	}
+	if !redacted {
+		return rawURL
+	}
+	return u.String()
+}
+
+// Payload returns the payload with sensitive values redacted.
+// Valid json has its (nested) sensitive keys redacted (and is returned unchanged if it has none),
+// while anything else (such as truncated json or form encoded text) is redacted with Text.
+func (r Redactor) Payload(payload string) string {
+	if len(payload) == 0 {
+		return payload
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil || decoder.More() {
+		return r.Text(payload)
+	}
+	if !r.redactJSON(decoded) {
+		return payload
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(decoded); err != nil {
+		return r.Text(payload)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// redactJSON recursively redacts the sensitive keys of decoded json objects in place,
+// returning true if anything was redacted
+func (r Redactor) redactJSON(decoded interface{}) bool {
+	redacted := false
+	switch v := decoded.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if r.IsSensitive(key) && value != nil {
+				v[key] = Redacted
+				redacted = true
+			} else if r.redactJSON(value) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if r.redactJSON(value) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+// Text redacts sensitive values from unstructured text, matching
+// json style "key": "value" or "key": value pairs, and form style key=value pairs.
+func (r Redactor) Text(text string) string {
+	for _, key := range r.keys() {
+		patterns := textPatterns(key)
+		text = patterns.jsonPattern.ReplaceAllString(text, `${1}"`+Redacted+`"`)
+		text = patterns.formPattern.ReplaceAllString(text, `${1}${2}`+Redacted)
+	}
+	return text
+}
+
+// redactPatterns are the compiled Text patterns of a key
+type redactPatterns struct {
+	jsonPattern *regexp.Regexp
+	formPattern *regexp.Regexp
+}
+
+// compiledPatterns caches the redactPatterns of each key, as Text is called for every logged request
+var compiledPatterns sync.Map
+
+func init() {
+	for _, key := range DefaultRedactKeys {
+		textPatterns(key)
+	}
+}
+
+// textPatterns returns the redactPatterns of the key, compiling them the first time the key is used
+func textPatterns(key string) redactPatterns {
+	if patterns, ok := compiledPatterns.Load(key); ok {
+		return patterns.(redactPatterns)
+	}
+	quotedKey := regexp.QuoteMeta(key)
+	patterns := redactPatterns{
+		jsonPattern: regexp.MustCompile(`(?i)("` + quotedKey + `"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^\s,}\]]+)`),
+		formPattern: regexp.MustCompile(`(?i)(^|[?&\s])(` + quotedKey + `=)[^&\s]*`),
+	}
+	compiledPatterns.Store(key, patterns)
+	return patterns
+}
+
+// Error returns the error with the Payload and URL redacted, if it is a RequestError
+func (r Redactor) Error(err error) error {
+	requestErr, ok := err.(RequestError)
+	if !ok {
+		return err
+	}
+	requestErr.Payload = r.Payload(requestErr.Payload)
+	requestErr.URL = r.URL(requestErr.URL)
+	return requestErr
+}
@@ -2,50 +2,92 @@ package cioutil
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/garyburd/go-oauth/oauth"
 	"github.com/pkg/errors"
 )
 
-// ClientRequest defines information that can be used to make a request
+// ClientRequest defines information that can be used to make a request.
+// Path is a template, such as /users/{id}/email_accounts/{label},
+// with each {name} placeholder filled in from PathValues.
 type ClientRequest struct {
 	Method      string
 	Path        string
+	PathValues  PathValues
 	FormValues  interface{}
 	QueryValues interface{}
 }
 
+// PathValues holds the values for the {name} placeholders in a ClientRequest Path
+type PathValues map[string]string
+
+// names returns the sorted names of the path values
+func (pathValues PathValues) names() []string {
+	names := make([]string, 0, len(pathValues))
+	for name := range pathValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolvedPath returns the Path with all placeholders replaced by their PathValues
+func (request ClientRequest) ResolvedPath() string {
+	path := request.Path
+	for name, value := range request.PathValues {
+		path = strings.Replace(path, "{"+name+"}", value, -1)
+	}
+	return path
+}
+
 // DoFormRequest makes the actual request
-func (cio Cio) DoFormRequest(request ClientRequest, result interface{}) error {
+func (cio Cio) DoFormRequest(request ClientRequest, result interface{}) (err error) {
+
+	// Trace the whole call, including any retries
+	ctx, span := cio.startSpan(cio.Context(), "CIO "+request.Method+" "+request.Path, request.traceAttributes())
+	defer func() { span.End(err) }()
+
+	// Validate the parameters before making any request
+	if err := request.Validate(); err != nil {
+		return RequestError{err, ErrorMetaData{Method: request.Method, URL: cio.Host + request.Path}}
+	}
 
 	// Construct the url
-	cioURL := cio.Host + request.Path + QueryString(request.QueryValues)
+	queryString, err := QueryString(request.QueryValues)
+	if err != nil {
+		return RequestError{errors.Wrap(err, "CIO: Failed to encode query values"), ErrorMetaData{Method: request.Method, URL: cio.Host + request.Path}}
+	}
+	cioURL := cio.Host + request.ResolvedPath() + queryString
 
 	// Construct the body
-	bodyValues := FormValues(request.FormValues)
+	bodyValues, err := FormValues(request.FormValues)
+	if err != nil {
+		return RequestError{errors.Wrap(err, "CIO: Failed to encode form values"), ErrorMetaData{Method: request.Method, URL: cioURL}}
+	}
 	bodyString := bodyValues.Encode()
-	logRequest(cio.Log, request.Method, cioURL, bodyValues)
+	logRequest(cio.Log, cio.Redactor, request.Method, cioURL, bodyValues)
 
-	statusCode, resBody, err := cio.createAndSendRequest(request, cioURL, bodyString, bodyValues, result)
+	statusCode, resBody, err := cio.attemptRequest(ctx, request, false, cioURL, bodyString, bodyValues, result)
 
-	// Retry if Status Code >= 500 and RetryServerErr is set to true
-	if cio.RetryServerErr && shouldRetryOnce(statusCode, err) {
-		time.Sleep(1 * time.Second)
-		logResponse(cio.Log, true, request.Method, cioURL, statusCode, resBody, errors.Cause(err))
-		statusCode, resBody, err = cio.createAndSendRequest(request, cioURL, bodyString, bodyValues, result)
+	// Retry if Status Code >= 500 and RetryServerErr is set to true (unless the context is done)
+	if cio.RetryServerErr && shouldRetryOnce(statusCode, err) && sleepContext(ctx, 1*time.Second) {
+		logResponse(cio.Log, cio.Redactor, true, request.Method, cioURL, statusCode, resBody, errors.Cause(cio.Redactor.Error(err)))
+		statusCode, resBody, err = cio.attemptRequest(ctx, request, true, cioURL, bodyString, bodyValues, result)
 	}
+	span.SetAttribute(TraceAttributeStatusCode, statusCode)
 
-	// Log the response
-	logResponse(cio.Log, false, request.Method, cioURL, statusCode, resBody, errors.Cause(err))
+	// Redact any sensitive information from the error, and log the response
+	err = cio.Redactor.Error(err)
+	logResponse(cio.Log, cio.Redactor, false, request.Method, cioURL, statusCode, resBody, errors.Cause(err))
 
 	return err
 }
@@ -58,9 +100,54 @@ func shouldRetryOnce(statusCode int, err error) bool {
 		(statusCode == 401 && strings.Contains(strings.ToLower(ErrorPayload(err)), "nonce"))
 }
 
+// sleepContext sleeps for the duration, returning false early if the context is done first
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// attemptRequest calls createAndSendRequest within its own span,
+// reporting the attempt to the Metrics (if any are set).
+// Returns the status code, the response body, and any error
+func (cio Cio) attemptRequest(ctx context.Context, request ClientRequest, retry bool, cioURL string, bodyString string, bodyValues url.Values, result interface{}) (int, string, error) {
+
+	attributes := request.traceAttributes()
+	attributes[TraceAttributeRetry] = retry
+	ctx, span := cio.startSpan(ctx, "CIO attempt "+request.Method+" "+request.Path, attributes)
+
+	start := time.Now()
+	statusCode, resBody, err := cio.createAndSendRequest(ctx, request, cioURL, bodyString, bodyValues, result)
+
+	span.SetAttribute(TraceAttributeStatusCode, statusCode)
+	span.End(err)
+
+	if cio.Metrics == nil {
+		return statusCode, resBody, err
+	}
+
+	cio.Metrics.ObserveRequest(RequestMetric{
+		Method:        request.Method,
+		Endpoint:      request.Path,
+		StatusCode:    statusCode,
+		Duration:      time.Since(start),
+		Retry:         retry,
+		BytesSent:     len(bodyString),
+		BytesReceived: len(resBody),
+		Err:           err,
+	})
+
+	return statusCode, resBody, err
+}
+
 // createAndSendRequest creates the body io.Reader, the *http.Request, and sends the request, logging the response.
 // Returns the status code, the response body, and any error
-func (cio Cio) createAndSendRequest(request ClientRequest, cioURL string, bodyString string, bodyValues url.Values, result interface{}) (int, string, error) {
+func (cio Cio) createAndSendRequest(ctx context.Context, request ClientRequest, cioURL string, bodyString string, bodyValues url.Values, result interface{}) (int, string, error) {
 
 	var bodyReader io.Reader
 	if len(bodyString) > 0 {
@@ -68,7 +155,7 @@ func (cio Cio) createAndSendRequest(request ClientRequest, cioURL string, bodySt
 	}
 
 	// Construct the request
-	httpReq, err := cio.createRequest(request, cioURL, bodyReader, bodyValues)
+	httpReq, err := cio.createRequest(ctx, request, cioURL, bodyReader, bodyValues)
 	if err != nil {
 		return 0, "", err
 	}
@@ -78,12 +165,13 @@ func (cio Cio) createAndSendRequest(request ClientRequest, cioURL string, bodySt
 }
 
 // createRequest creates the *http.Request object
-func (cio Cio) createRequest(request ClientRequest, cioURL string, bodyReader io.Reader, bodyValues url.Values) (*http.Request, error) {
+func (cio Cio) createRequest(ctx context.Context, request ClientRequest, cioURL string, bodyReader io.Reader, bodyValues url.Values) (*http.Request, error) {
 	// Construct the request
 	httpReq, err := http.NewRequest(request.Method, cioURL, bodyReader)
 	if err != nil {
 		return httpReq, RequestError{errors.Wrap(err, "CIO: Failed to form request"), ErrorMetaData{Method: request.Method, URL: cioURL}}
 	}
+	httpReq = httpReq.WithContext(ctx)
 
 	// oAuth signature
 	var client oauth.Client
@@ -141,88 +229,49 @@ func (cio Cio) sendRequest(httpReq *http.Request, result interface{}, cioURL str
 	return res.StatusCode, resBodyString, nil
 }
 
-// logRequest logs the request about to be made to CIO, redacting sensitive information in the body
-func logRequest(log Logger, method string, cioURL string, bodyValues url.Values) {
+// logRequest logs the request about to be made to CIO, redacting sensitive information in the url and body
+func logRequest(log LeveledLogger, redactor Redactor, method string, cioURL string, bodyValues url.Values) {
 	if log != nil {
-
-		// Copy url.Values
-		redactedValues := url.Values{}
-		for k, v := range bodyValues {
-			redactedValues[k] = v
-		}
-
-		// Redact sensitive information
-		if val := redactedValues.Get("password"); len(val) > 0 {
-			redactedValues.Set("password", "redacted")
-		}
-		if val := redactedValues.Get("provider_refresh_token"); len(val) > 0 {
-			redactedValues.Set("provider_refresh_token", "redacted")
-		}
-		if val := redactedValues.Get("provider_consumer_key"); len(val) > 0 {
-			redactedValues.Set("provider_consumer_key", "redacted")
-		}
-		if val := redactedValues.Get("provider_consumer_secret"); len(val) > 0 {
-			redactedValues.Set("provider_consumer_secret", "redacted")
-		}
-
-		// Actually log
-		if logrusLogger, ok := log.(*logrus.Logger); ok {
-			// If logrus, use structured fields
-			logrusLogger.WithFields(logrus.Fields{"httpMethod": method, "url": cioURL, "payload": redactedValues.Encode()}).Debug("Creating new request to CIO")
-		} else {
-			// Else just log with Println
-			log.Printf("Creating new %s request to: %s with payload: %s\n", method, cioURL, redactedValues.Encode())
-		}
+		log.Debug("Creating new request to CIO",
+			LogFieldHTTPMethod, method,
+			LogFieldURL, redactor.URL(cioURL),
+			LogFieldPayload, redactor.Values(bodyValues).Encode())
 	}
 }
 
 // logBodyCloseError logs any error that happens when trying to close the *http.Response.Body
-func logBodyCloseError(log Logger, closeError error) {
+func logBodyCloseError(log LeveledLogger, closeError error) {
 	if log != nil {
-		if logrusLogger, ok := log.(*logrus.Logger); ok {
-			// If logrus, use structured fields
-			logrusLogger.WithError(closeError).Warn("Unable to close response body from CIO")
-		} else {
-			// Else just log with Println
-			log.Printf("Unable to close response body from CIO, with error: %s\n", closeError.Error())
-		}
+		log.Warn("Unable to close response body from CIO", LogFieldError, closeError)
 	}
 }
 
 // logResponse logs the response from CIO, if any logger is set
-func logResponse(log Logger, retry bool, method string, cioURL string, statusCode int, responseBody string, err error) {
+func logResponse(log LeveledLogger, redactor Redactor, retry bool, method string, cioURL string, statusCode int, responseBody string, err error) {
 	if log != nil {
 
-		// TODO: redact access_token and access_token_secret before logging (only occurs with 3-legged oauth [rare])
+		// Redact sensitive information (such as access_token and access_token_secret) before truncating
+		responseBody = redactor.Payload(responseBody)
 
 		// Take only the first 2000 characters from the responseBody, which should be more than enough to debug anything
 		if bodyLen := len(responseBody); bodyLen > 2000 {
 			responseBody = responseBody[:2000]
 		}
 
-		if logrusLogger, ok := log.(*logrus.Logger); ok {
-			// If logrus, use structured fields
-			logEntry := logrusLogger.WithFields(logrus.Fields{
-				"httpMethod":     method,
-				"url":            cioURL,
-				"statusCode":     fmt.Sprintf("%d", statusCode),
-				"payloadSnippet": responseBody})
-			if !retry && (err != nil || statusCode >= 400) {
-				if err != nil {
-					logEntry = logEntry.WithError(err)
-				}
-				logEntry.Warn("Received response from CIO")
-			} else {
-				logEntry.Debug("Received response from CIO")
-			}
+		keysAndValues := []interface{}{
+			LogFieldHTTPMethod, method,
+			LogFieldURL, redactor.URL(cioURL),
+			LogFieldStatusCode, statusCode,
+			LogFieldPayloadSnippet, responseBody,
+		}
+		if err != nil {
+			keysAndValues = append(keysAndValues, LogFieldError, err)
+		}
 
+		if !retry && (err != nil || statusCode >= 400) {
+			log.Warn("Received response from CIO", keysAndValues...)
 		} else {
-			// Else just log with Println
-			if err != nil {
-				log.Printf("Received response from %s to: %s with status code: %d and error: %s and payload snippet: %s\n", method, cioURL, statusCode, err, responseBody)
-			} else {
-				log.Printf("Received response from %s to: %s with status code: %d and payload snippet: %s\n", method, cioURL, statusCode, responseBody)
-			}
+			log.Debug("Received response from CIO", keysAndValues...)
 		}
 	}
 }
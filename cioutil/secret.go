@@ -0,0 +1,55 @@
+package cioutil
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// Secret is a string type for secret-bearing fields (such as passwords, refresh tokens, and consumer secrets).
+// It redacts itself when printed with fmt (including %+v dumps of its parent struct) or marshaled to json,
+// so that it can not end up in logs by accident. Use Reveal to get the actual value.
+// It is still sent with its actual value in form values and query strings, and unmarshaled from json as a string.
+type Secret string
+
+// Reveal returns the actual value of the Secret
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String returns Redacted, or an empty string if the Secret is empty
+func (s Secret) String() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return Redacted
+}
+
+// Format implements fmt.Formatter, so that every verb (including %v, %+v, %#v, %s, %q, and %x) is redacted
+func (s Secret) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'q':
+		_, _ = io.WriteString(f, strconv.Quote(s.String()))
+	case verb == 'v' && f.Flag('#'):
+		_, _ = io.WriteString(f, "cioutil.Secret("+strconv.Quote(s.String())+")")
+	default:
+		_, _ = io.WriteString(f, s.String())
+	}
+}
+
+// MarshalJSON implements json.Marshaler, marshaling the Secret as Redacted (or an empty string if empty)
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(s.String())), nil
+}
+
+// MarshalText implements encoding.TextMarshaler, marshaling the Secret as Redacted (or an empty string if empty)
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// EncodeFormValues implements FormEncoder, so that the actual value is sent to CIO
+func (s Secret) EncodeFormValues(key string, values url.Values) error {
+	values.Add(key, string(s))
+	return nil
+}
@@ -0,0 +1,65 @@
+package cioutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestSecret tests that a Secret redacts itself unless revealed
+func TestSecret(t *testing.T) {
+	t.Parallel()
+
+	params := struct {
+		Key    string `json:"key"`
+		Secret Secret `json:"secret,omitempty"`
+	}{
+		Key:    "consumer-key",
+		Secret: "hunter2",
+	}
+
+	if params.Secret.Reveal() != "hunter2" {
+		t.Error("Expected: hunter2; Got: ", params.Secret.Reveal())
+	}
+	if params.Secret.String() != Redacted || Secret("").String() != "" {
+		t.Error("Expected: ", Redacted, "; Got: ", params.Secret.String())
+	}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%10s"} {
+		printed := fmt.Sprintf(format, params)
+		if strings.Contains(printed, "hunter2") || !strings.Contains(printed, Redacted) {
+			t.Error("Expected redacted secret with ", format, "; Got: ", printed)
+		}
+	}
+	if printed := fmt.Sprintf("%q", params.Secret); printed != `"redacted"` {
+		t.Error("Expected: \"redacted\"; Got: ", printed)
+	}
+	if printed := fmt.Sprintf("%#v", params.Secret); printed != `cioutil.Secret("redacted")` {
+		t.Error("Expected: cioutil.Secret(\"redacted\"); Got: ", printed)
+	}
+
+	// JSON
+	marshaled, err := json.Marshal(params)
+	if err != nil || string(marshaled) != `{"key":"consumer-key","secret":"redacted"}` {
+		t.Error("Expected redacted json; Got: ", string(marshaled), "; With Error: ", err)
+	}
+
+	var unmarshaled struct {
+		Secret Secret `json:"secret"`
+	}
+	if err := json.Unmarshal([]byte(`{"secret":"hunter2"}`), &unmarshaled); err != nil || unmarshaled.Secret.Reveal() != "hunter2" {
+		t.Error("Expected: hunter2; Got: ", unmarshaled.Secret.Reveal(), "; With Error: ", err)
+	}
+
+	// The actual value is sent to CIO, and omitted if empty
+	formValues, err := FormValues(params)
+	if err != nil || formValues.Get("secret") != "hunter2" {
+		t.Error("Expected: hunter2; Got: ", formValues, "; With Error: ", err)
+	}
+	params.Secret = ""
+	formValues, err = FormValues(params)
+	if _, ok := formValues["secret"]; err != nil || ok {
+		t.Error("Expected no secret; Got: ", formValues, "; With Error: ", err)
+	}
+}
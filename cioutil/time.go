@@ -0,0 +1,12 @@
+package cioutil
+
+import "time"
+
+// UnixTime converts a Unix timestamp (as returned by CIO) into a time.Time,
+// returning the zero time.Time if the timestamp is 0 (unset).
+func UnixTime(timestamp int) time.Time {
+	if timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(timestamp), 0)
+}
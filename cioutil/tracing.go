@@ -0,0 +1,57 @@
+package cioutil
+
+import (
+	"context"
+	"strings"
+)
+
+// Tracer interface allows injection of a distributed tracing implementation (such as an OpenTelemetry adapter).
+// DoFormRequest starts one span per logical call, with a child span for each attempt (including retries),
+// as children of any span already in the context set with Cio.WithContext.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, Span)
+}
+
+// Span interface is a single span started by a Tracer
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// Attribute keys set on the spans started by DoFormRequest
+const (
+	TraceAttributeMethod     = "cio.method"
+	TraceAttributePath       = "cio.path"
+	TraceAttributeUserID     = "cio.user_id"
+	TraceAttributeStatusCode = "cio.status_code"
+	TraceAttributeRetry      = "cio.retry"
+)
+
+// noopSpan is the Span used when no Tracer is set
+type noopSpan struct{}
+
+// SetAttribute does nothing
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+
+// End does nothing
+func (noopSpan) End(err error) {}
+
+// startSpan starts a span with the Tracer, or returns a noopSpan if no Tracer is set
+func (cio Cio) startSpan(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, Span) {
+	if cio.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return cio.Tracer.StartSpan(ctx, name, attributes)
+}
+
+// traceAttributes returns the span attributes describing the request: method, templated path, and user id (if any)
+func (request ClientRequest) traceAttributes() map[string]interface{} {
+	attributes := map[string]interface{}{
+		TraceAttributeMethod: request.Method,
+		TraceAttributePath:   request.Path,
+	}
+	if userID, ok := request.PathValues["id"]; ok && strings.HasPrefix(request.Path, "/users/{id}") {
+		attributes[TraceAttributeUserID] = userID
+	}
+	return attributes
+}
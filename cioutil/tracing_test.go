@@ -0,0 +1,139 @@
+package cioutil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSpanKey is the context key that testTracer stores the current span under
+type testSpanKey struct{}
+
+// testSpan records its name, parent, attributes and error
+type testSpan struct {
+	name       string
+	parent     *testSpan
+	attributes map[string]interface{}
+	ended      bool
+	err        error
+}
+
+// SetAttribute implements Span
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+// End implements Span
+func (s *testSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+// testTracer records every span it starts
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+// StartSpan implements Tracer
+func (tr *testTracer) StartSpan(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, Span) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{name: name, parent: parent, attributes: attributes}
+	tr.spans = append(tr.spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+// TestDoFormRequestTracing tests that one span per call and one child span per attempt are started
+func TestDoFormRequestTracing(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, err := io.WriteString(w, `{"success":true}`)
+		if err != nil {
+			panic(err)
+		}
+	}))
+	defer testServer.Close()
+
+	tracer := &testTracer{}
+	cio := NewCio("key", "secret", nil, testServer.URL, 5*time.Second)
+	cio.RetryServerErr = true
+	cio.Tracer = tracer
+
+	rootSpan := &testSpan{name: "root", attributes: map[string]interface{}{}}
+	ctx := context.WithValue(context.Background(), testSpanKey{}, rootSpan)
+
+	var result map[string]interface{}
+	err := cio.WithContext(ctx).DoFormRequest(ClientRequest{
+		Method:     "GET",
+		Path:       "/users/{id}/email_accounts/{label}",
+		PathValues: PathValues{"id": "abc123", "label": "test::gmail"},
+	}, &result)
+
+	if err != nil {
+		t.Fatal("Expected successful request; Got: ", err)
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatal("Expected 3 spans; Got: ", len(tracer.spans))
+	}
+
+	call, first, second := tracer.spans[0], tracer.spans[1], tracer.spans[2]
+
+	if call.parent != rootSpan || !call.ended || call.err != nil ||
+		call.attributes[TraceAttributeMethod] != "GET" ||
+		call.attributes[TraceAttributePath] != "/users/{id}/email_accounts/{label}" ||
+		call.attributes[TraceAttributeUserID] != "abc123" ||
+		call.attributes[TraceAttributeStatusCode] != 200 {
+		t.Error("Unexpected call span: ", call)
+	}
+
+	if first.parent != call || !first.ended || first.err == nil ||
+		first.attributes[TraceAttributeRetry] != false ||
+		first.attributes[TraceAttributeStatusCode] != 502 {
+		t.Error("Unexpected first attempt span: ", first)
+	}
+
+	if second.parent != call || !second.ended || second.err != nil ||
+		second.attributes[TraceAttributeRetry] != true ||
+		second.attributes[TraceAttributeStatusCode] != 200 {
+		t.Error("Unexpected second attempt span: ", second)
+	}
+}
+
+// TestDoFormRequestContextCanceled tests that a canceled context stops the request
+func TestDoFormRequestContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to be made; Got: ", r.URL)
+	}))
+	defer testServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cio := NewCio("key", "secret", nil, testServer.URL, 5*time.Second).WithContext(ctx)
+	cio.RetryServerErr = true
+
+	start := time.Now()
+	err := cio.DoFormRequest(ClientRequest{Method: "GET", Path: "/users"}, nil)
+
+	if err == nil || ErrorStatusCode(err) != 0 {
+		t.Error("Expected connection error; Got: ", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Expected no retry delay with a canceled context; Took: ", time.Since(start))
+	}
+}
@@ -2,6 +2,7 @@ package cioutil
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -12,15 +13,20 @@ import (
 )
 
 // Cio struct contains the api key and secret, and other configuration settings,
-// along with an optional logger, and provides access to methods used by all
+// along with an optional logger, metrics and tracer, and provides access to methods used by all
 // ContextIO structs/object.
 type Cio struct {
 	apiKey         string
 	apiSecret      string
-	Log            Logger
+	Log            LeveledLogger
 	Host           string
 	RequestTimeout time.Duration
 	RetryServerErr bool
+	Metrics        Metrics
+	Tracer         Tracer
+	Redactor       Redactor
+
+	ctx context.Context
 }
 
 // NewCio returns a CIO struct for embedding in a concrete type.
@@ -28,12 +34,27 @@ func NewCio(key string, secret string, logger Logger, host string, requestTimeou
 	return Cio{
 		apiKey:         key,
 		apiSecret:      secret,
-		Log:            logger,
+		Log:            NewLeveledLogger(logger),
 		Host:           host,
 		RequestTimeout: requestTimeout,
 	}
 }
 
+// WithContext returns a copy of the Cio that makes all requests with the given context,
+// which is used for cancellation and as the parent of any tracing spans.
+func (cio Cio) WithContext(ctx context.Context) Cio {
+	cio.ctx = ctx
+	return cio
+}
+
+// Context returns the context requests are made with, which defaults to context.Background()
+func (cio Cio) Context() context.Context {
+	if cio.ctx == nil {
+		return context.Background()
+	}
+	return cio.ctx
+}
+
 // ValidateCallback returns true if this Webhook Callback or User Account Status Callback authenticates
 func (cio Cio) ValidateCallback(token string, signature string, timestamp int) bool {
 	// Hash timestamp and token with secret, compare to signature
@@ -52,7 +73,8 @@ func hashHmac(hashAlgorithm func() hash.Hash, message string, secret string) str
 }
 
 // Logger interface which *log.Logger uses.
-// Allows injection of user specified loggers, such as log.Logger or logrus.
+// Allows injection of user specified loggers, such as log.Logger or logrus,
+// which are adapted to a LeveledLogger with NewLeveledLogger.
 type Logger interface {
 	Printf(format string, v ...interface{})
 }
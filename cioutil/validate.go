@@ -0,0 +1,264 @@
+package cioutil
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationError is the error returned (as the cause of a RequestError) when
+// a request's path values, query values, or form values fail validation.
+// No request is made to CIO if validation fails.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// FieldError describes a single field that failed validation:
+// the Field name (json tag name or path value name), the Rule that failed, and a Message
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Error returns a description of all fields that failed validation
+func (e ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%s %s", field.Field, field.Message))
+	}
+	return "CIO: Invalid request parameters: " + strings.Join(messages, "; ")
+}
+
+// ParamsValidator can be implemented by params structs that need rules across
+// multiple fields (such as mutually exclusive fields), which the `valid` tags can not express.
+// ValidateParams returns a FieldError for each field that fails.
+type ParamsValidator interface {
+	ValidateParams() []FieldError
+}
+
+// paramsValidatorType is the reflect.Type of the ParamsValidator interface
+var paramsValidatorType = reflect.TypeOf((*ParamsValidator)(nil)).Elem()
+
+// AsValidationError returns the ValidationError if err is (or was caused by) one
+func AsValidationError(err error) (ValidationError, bool) {
+	validationErr, ok := errors.Cause(err).(ValidationError)
+	return validationErr, ok
+}
+
+// Validate checks the `valid` struct tags of the params struct, returning a ValidationError if any fail.
+// Rules are comma separated, and can be:
+// required (must not be the zero value), url (must be an absolute http or https url),
+// and in(a|b|c) (must be one of the listed values, case insensitive).
+// The url and in rules are only checked when the field is not empty.
+// Params (or nested structs) implementing ParamsValidator are also checked with ValidateParams.
+func Validate(params interface{}) error {
+	var fieldErrors []FieldError
+	if params != nil {
+		fieldErrors = validateStruct("", reflect.ValueOf(params))
+	}
+	if len(fieldErrors) > 0 {
+		return ValidationError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+// ValidatePathValues checks that each path value is a sane, non-empty, single path segment,
+// returning a ValidationError if any are not.
+func ValidatePathValues(pathValues PathValues) error {
+	if fieldErrors := validatePathValues(pathValues); len(fieldErrors) > 0 {
+		return ValidationError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+// Validate checks the path values, query values, and form values of the request,
+// returning a ValidationError with all fields that failed.
+func (request ClientRequest) Validate() error {
+	fieldErrors := validatePathValues(request.PathValues)
+	if request.QueryValues != nil {
+		fieldErrors = append(fieldErrors, validateStruct("", reflect.ValueOf(request.QueryValues))...)
+	}
+	if request.FormValues != nil {
+		fieldErrors = append(fieldErrors, validateStruct("", reflect.ValueOf(request.FormValues))...)
+	}
+	if len(fieldErrors) > 0 {
+		return ValidationError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+// validatePathValues returns a FieldError for each path value that is not a sane path segment
+func validatePathValues(pathValues PathValues) []FieldError {
+	var fieldErrors []FieldError
+	for _, name := range pathValues.names() {
+		value := pathValues[name]
+		switch {
+		case len(value) == 0:
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Rule: "required", Message: "is required"})
+		case value == "." || value == "..":
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Rule: "path", Message: "must not be a relative path"})
+		case strings.IndexFunc(value, invalidPathRune) >= 0:
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Rule: "path", Message: "must be a single path segment"})
+		}
+	}
+	return fieldErrors
+}
+
+// invalidPathRune returns true for runes that can not appear within a single path segment
+func invalidPathRune(r rune) bool {
+	return r == '/' || r == '\\' || r == '?' || r == '#' || unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+// validateStruct dynamically iterates through the struct fields, checking each `valid` tag
+func validateStruct(prefix string, refVal reflect.Value) []FieldError {
+	for refVal.Kind() == reflect.Ptr {
+		if refVal.IsNil() {
+			return nil
+		}
+		refVal = refVal.Elem()
+	}
+	if refVal.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fieldErrors []FieldError
+	refType := refVal.Type()
+	for i, numFields := 0, refVal.NumField(); i < numFields; i++ {
+		fieldValue := refVal.Field(i)
+		fieldType := refType.Field(i)
+
+		// Embedded structs without a json tag are flattened into the parent
+		if fieldType.Anonymous && len(fieldType.Tag.Get("json")) == 0 {
+			fieldErrors = append(fieldErrors, validateStruct(prefix, fieldValue)...)
+			continue
+		}
+
+		// Skip unexported fields
+		if len(fieldType.PkgPath) > 0 {
+			continue
+		}
+
+		name := fieldType.Name
+		if jsonName, _, err := formName(fieldType); err == nil {
+			name = jsonName
+		}
+		if len(prefix) > 0 {
+			name = fmt.Sprintf("%s[%s]", prefix, name)
+		}
+
+		for _, rule := range splitRules(fieldType.Tag.Get("valid")) {
+			if fieldError, ok := checkRule(name, rule, fieldValue); !ok {
+				fieldErrors = append(fieldErrors, fieldError)
+			}
+		}
+
+		// Nested structs (other than time.Time) are validated with their name as a prefix
+		nested := fieldValue
+		if nested.Kind() == reflect.Ptr && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct && nested.Type() != timeType {
+			fieldErrors = append(fieldErrors, validateStruct(name, nested)...)
+		}
+	}
+
+	if validator, ok := asParamsValidator(refVal); ok {
+		for _, fieldError := range validator.ValidateParams() {
+			if len(prefix) > 0 {
+				fieldError.Field = fmt.Sprintf("%s[%s]", prefix, fieldError.Field)
+			}
+			fieldErrors = append(fieldErrors, fieldError)
+		}
+	}
+	return fieldErrors
+}
+
+// asParamsValidator returns the ParamsValidator implemented by the struct (or by a pointer to it), if any
+func asParamsValidator(refVal reflect.Value) (ParamsValidator, bool) {
+	if !refVal.CanInterface() {
+		return nil, false
+	}
+	if refVal.Type().Implements(paramsValidatorType) {
+		validator, ok := refVal.Interface().(ParamsValidator)
+		return validator, ok
+	}
+	if reflect.PtrTo(refVal.Type()).Implements(paramsValidatorType) {
+		ptr := reflect.New(refVal.Type())
+		ptr.Elem().Set(refVal)
+		return ptr.Interface().(ParamsValidator), true
+	}
+	return nil, false
+}
+
+// splitRules splits the `valid` tag into its rules
+func splitRules(tag string) []string {
+	var rules []string
+	for _, rule := range strings.Split(tag, ",") {
+		if rule = strings.TrimSpace(rule); len(rule) > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checkRule checks a single rule against the field value, returning false and a FieldError if it fails
+func checkRule(name string, rule string, fieldValue reflect.Value) (FieldError, bool) {
+
+	// Nil pointers are missing, while non-nil pointers are present (even if pointing at a zero value)
+	empty := isEmptyValue(fieldValue)
+	if fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+		empty = false
+		fieldValue = fieldValue.Elem()
+	}
+
+	switch {
+	case rule == "required":
+		if empty {
+			return FieldError{Field: name, Rule: rule, Message: "is required"}, false
+		}
+
+	case rule == "optional":
+		// Nothing to check
+
+	case rule == "url":
+		if !empty && fieldValue.Kind() == reflect.String && !isHTTPURL(fieldValue.String()) {
+			return FieldError{Field: name, Rule: rule, Message: "must be an absolute http or https url"}, false
+		}
+
+	case strings.HasPrefix(rule, "in(") && strings.HasSuffix(rule, ")"):
+		allowed := strings.Split(rule[len("in("):len(rule)-1], "|")
+		if !empty && fieldValue.Kind() == reflect.String && !containsFold(allowed, fieldValue.String()) {
+			return FieldError{Field: name, Rule: "in", Message: "must be one of: " + strings.Join(allowed, ", ")}, false
+		}
+
+	default:
+		return FieldError{Field: name, Rule: rule, Message: "has unknown validation rule " + rule}, false
+	}
+
+	return FieldError{}, true
+}
+
+// isHTTPURL returns true if the string is an absolute http or https url with a host
+func isHTTPURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return (scheme == "http" || scheme == "https") && len(u.Host) > 0
+}
+
+// containsFold returns true if the slice contains the string, ignoring case
+func containsFold(slice []string, s string) bool {
+	for _, v := range slice {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package cioutil
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// TestValidate tests the required, url, and in validation rules
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	type params struct {
+		Required    string `json:"required" valid:"required"`
+		RequiredPtr *bool  `json:"required_ptr,omitempty" valid:"required"`
+		URL         string `json:"url,omitempty" valid:"url"`
+		Enum        string `json:"enum,omitempty" valid:"in(text/plain|text/html)"`
+		Unchecked   string `json:"unchecked,omitempty"`
+	}
+
+	falseValue := false
+
+	valid := params{Required: "set", RequiredPtr: &falseValue, URL: "https://example.com/callback", Enum: "TEXT/HTML"}
+	if err := Validate(valid); err != nil {
+		t.Error("Expected valid params; Got: ", err)
+	}
+
+	// Empty optional fields are not checked against url or in rules
+	if err := Validate(params{Required: "set", RequiredPtr: &falseValue}); err != nil {
+		t.Error("Expected valid params; Got: ", err)
+	}
+
+	expected := ValidationError{Fields: []FieldError{
+		{Field: "required", Rule: "required", Message: "is required"},
+		{Field: "required_ptr", Rule: "required", Message: "is required"},
+		{Field: "url", Rule: "url", Message: "must be an absolute http or https url"},
+		{Field: "enum", Rule: "in", Message: "must be one of: text/plain, text/html"},
+	}}
+
+	err := Validate(&params{URL: "/relative", Enum: "text/xml"})
+	if validationErr, ok := AsValidationError(err); !ok || !reflect.DeepEqual(validationErr, expected) {
+		t.Error("Expected validation error: ", expected, "; Got: ", err)
+	}
+}
+
+// exclusiveParams implements ParamsValidator, requiring exactly one of A or B
+type exclusiveParams struct {
+	A string `json:"a,omitempty"`
+	B string `json:"b,omitempty"`
+}
+
+// ValidateParams implements ParamsValidator
+func (p exclusiveParams) ValidateParams() []FieldError {
+	if (len(p.A) > 0) == (len(p.B) > 0) {
+		return []FieldError{{Field: "a", Rule: "exclusive", Message: "requires exactly one of a or b"}}
+	}
+	return nil
+}
+
+// TestValidateParamsValidator tests that ParamsValidator is checked, including on nested structs
+func TestValidateParamsValidator(t *testing.T) {
+	t.Parallel()
+
+	if err := Validate(exclusiveParams{A: "set"}); err != nil {
+		t.Error("Expected valid params; Got: ", err)
+	}
+
+	type nestedParams struct {
+		Nested exclusiveParams `json:"nested"`
+	}
+
+	expected := ValidationError{Fields: []FieldError{{Field: "nested[a]", Rule: "exclusive", Message: "requires exactly one of a or b"}}}
+
+	err := Validate(&nestedParams{Nested: exclusiveParams{A: "set", B: "set"}})
+	if validationErr, ok := AsValidationError(err); !ok || !reflect.DeepEqual(validationErr, expected) {
+		t.Error("Expected validation error: ", expected, "; Got: ", err)
+	}
+}
+
+// TestValidatePathValues tests the sanity checks on path segments
+func TestValidatePathValues(t *testing.T) {
+	t.Parallel()
+
+	if err := ValidatePathValues(PathValues{"id": "5727aa2a0be9af5d658b4568", "label": "test::gmail"}); err != nil {
+		t.Error("Expected valid path values; Got: ", err)
+	}
+
+	expected := ValidationError{Fields: []FieldError{
+		{Field: "folder", Rule: "path", Message: "must not be a relative path"},
+		{Field: "id", Rule: "required", Message: "is required"},
+		{Field: "label", Rule: "path", Message: "must be a single path segment"},
+	}}
+
+	err := ValidatePathValues(PathValues{"id": "", "label": "test/../gmail", "folder": ".."})
+	if validationErr, ok := AsValidationError(err); !ok || !reflect.DeepEqual(validationErr, expected) {
+		t.Error("Expected validation error: ", expected, "; Got: ", err)
+	}
+}
+
+// TestDoFormRequestValidation tests that DoFormRequest returns a validation error without making a request
+func TestDoFormRequestValidation(t *testing.T) {
+	t.Parallel()
+
+	cio := NewCio("key", "secret", nil, "http://127.0.0.1:0", 0)
+
+	err := cio.DoFormRequest(ClientRequest{
+		Method:     "GET",
+		Path:       "/users/{id}",
+		PathValues: PathValues{"id": "a/b"},
+		QueryValues: struct {
+			Email string `json:"email" valid:"required"`
+		}{},
+	}, nil)
+
+	if _, ok := err.(RequestError); !ok {
+		t.Error("Expected RequestError; Got: ", err)
+	}
+
+	validationErr, ok := errors.Cause(err).(ValidationError)
+	if !ok || len(validationErr.Fields) != 2 {
+		t.Error("Expected validation error with 2 fields; Got: ", err)
+	}
+}